@@ -0,0 +1,201 @@
+package client
+
+import (
+	"concurrent-sql/util"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Session is a dedicated connection running statements in order, so USE, SET
+// and transactions apply to the statements after them, unlike *sql.DB which may
+// run each statement on another pooled connection.
+//
+// When the connection breaks, the statement fails and the next one runs on a
+// new connection, after the last USE and the last SET of each variable run so
+// far are replayed on it. A transaction open on the broken connection is lost.
+type Session struct {
+	DSN        string
+	Reconnects int
+	Log        *log.Logger
+
+	db   *sql.DB
+	conn *sql.Conn // nil after the connection broke.
+	// the last USE and SET statement of each variable, replayed after a
+	// reconnect in the order they last ran.
+	state      map[string]string
+	stateOrder []string
+	inTxn      bool
+}
+
+// NewSession connects to the dsn.
+func NewSession(dsn string) (*Session, error) {
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// a connection given back is not reused, a new session gets a new one.
+	db.SetMaxIdleConns(0)
+	s := &Session{DSN: dsn, db: db}
+	if err := s.connect(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Session) connect() error {
+	ctx := context.Background()
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	if err := conn.PingContext(ctx); err != nil {
+		_ = conn.Close()
+		return err
+	}
+	for _, q := range s.replayed() {
+		if _, err := conn.ExecContext(ctx, q); err != nil {
+			_ = conn.Close()
+			return fmt.Errorf("restore session by %s: %v", q, err)
+		}
+	}
+	s.conn = conn
+	return nil
+}
+
+// IsBadConn returns whether the error means the connection is broken, the
+// session reconnects at the next statement then.
+func IsBadConn(err error) bool {
+	return err == driver.ErrBadConn || err == mysql.ErrInvalidConn || err == sql.ErrConnDone
+}
+
+// give up the broken connection, the next statement reconnects.
+func (s *Session) drop() {
+	_ = s.conn.Close()
+	s.conn = nil
+	if s.inTxn {
+		util.Logger(s.Log).Println("connection broken in a transaction, the transaction is lost")
+		s.inTxn = false
+	}
+}
+
+// track the statements changing the session, err is the error of the statement.
+// A failed COMMIT ends the transaction too, it is rolled back.
+func (s *Session) track(query string, err error) {
+	// the statements of a sql file keep their semicolon, sample: BEGIN;
+	q := strings.ToLower(strings.TrimRight(strings.TrimSpace(query), "; \t\r\n"))
+	switch {
+	case strings.HasPrefix(q, "commit") || strings.HasPrefix(q, "rollback"):
+		s.inTxn = false
+	case err != nil:
+	case strings.HasPrefix(q, "use ") || strings.HasPrefix(q, "set ") && !strings.HasPrefix(q, "set transaction"):
+		s.setState(stateKey(q), query)
+	case q == "begin" || strings.HasPrefix(q, "begin ") || strings.HasPrefix(q, "start transaction"):
+		s.inTxn = true
+	}
+}
+
+// the variable a USE or SET statement changes, q is in lower case, sample:
+// use for USE test, tidb_txn_mode for SET @@session.tidb_txn_mode = 'pessimistic'.
+func stateKey(q string) string {
+	if strings.HasPrefix(q, "use ") {
+		return "use"
+	}
+	name := strings.TrimPrefix(q, "set ")
+	if i := strings.Index(name, "="); i >= 0 {
+		name = strings.TrimSuffix(strings.TrimSpace(name[:i]), ":")
+	} else {
+		// sample: SET NAMES utf8mb4
+		name = strings.Fields(name)[0]
+	}
+	name = strings.TrimSpace(name)
+	for _, scope := range []string{"@@session.", "@@local.", "@@", "session ", "local "} {
+		name = strings.TrimPrefix(name, scope)
+	}
+	return strings.TrimSpace(name)
+}
+
+// keep the statement as the last one changing key, replacing the previous one.
+func (s *Session) setState(key, query string) {
+	if s.state == nil {
+		s.state = make(map[string]string)
+	}
+	if _, ok := s.state[key]; ok {
+		for i, k := range s.stateOrder {
+			if k == key {
+				s.stateOrder = append(s.stateOrder[:i], s.stateOrder[i+1:]...)
+				break
+			}
+		}
+	}
+	s.state[key] = query
+	s.stateOrder = append(s.stateOrder, key)
+}
+
+// the statements replayed after a reconnect, in order.
+func (s *Session) replayed() []string {
+	queries := make([]string, 0, len(s.stateOrder))
+	for _, key := range s.stateOrder {
+		queries = append(queries, s.state[key])
+	}
+	return queries
+}
+
+// Exec runs the statement on the connection of the session, reconnecting first
+// if the last statement broke it.
+func (s *Session) Exec(query string) (sql.Result, error) {
+	if err := s.reconnect(); err != nil {
+		return nil, err
+	}
+	result, err := s.conn.ExecContext(context.Background(), query)
+	if IsBadConn(err) {
+		s.drop()
+		return nil, err
+	}
+	s.track(query, err)
+	return result, err
+}
+
+// Query runs the statement like Exec, and returns its rows.
+func (s *Session) Query(query string) (*sql.Rows, error) {
+	if err := s.reconnect(); err != nil {
+		return nil, err
+	}
+	rows, err := s.conn.QueryContext(context.Background(), query)
+	if IsBadConn(err) {
+		s.drop()
+		return nil, err
+	}
+	s.track(query, err)
+	return rows, err
+}
+
+// connect again if the last statement broke the connection.
+func (s *Session) reconnect() error {
+	if s.conn != nil {
+		return nil
+	}
+	s.Reconnects++
+	util.Logger(s.Log).Println("reconnecting the session")
+	return s.connect()
+}
+
+// InTxn returns whether a transaction begun by the statements is open.
+func (s *Session) InTxn() bool {
+	return s.inTxn
+}
+
+// Close the connection of the session.
+func (s *Session) Close() error {
+	if s.conn != nil {
+		_ = s.conn.Close()
+		s.conn = nil
+	}
+	return s.db.Close()
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestSession_Track(t *testing.T) {
+	s := &Session{}
+	failed := errors.New("write conflict")
+	steps := []struct {
+		query string
+		err   error
+		inTxn bool
+	}{
+		{"USE test2", nil, false},
+		{"set @@tidb_txn_mode = 'pessimistic'", nil, false},
+		{"SET TRANSACTION ISOLATION LEVEL READ COMMITTED", nil, false},
+		{"set @a = 1", failed, false},
+		{"BEGIN", nil, true},
+		{"update t set k = k + 1", nil, true},
+		{"COMMIT", failed, false},
+		{"BEGIN;", nil, true},
+		{"COMMIT ;\n", nil, false},
+		{"BEGIN PESSIMISTIC;", nil, true},
+		{"ROLLBACK;", nil, false},
+		{"start transaction", nil, true},
+		{"rollback", nil, false},
+	}
+	for _, step := range steps {
+		s.track(step.query, step.err)
+		if s.InTxn() != step.inTxn {
+			t.Fatalf("bad transaction state after %s: %v", step.query, s.InTxn())
+		}
+	}
+	if expect := []string{"USE test2", "set @@tidb_txn_mode = 'pessimistic'"}; !reflect.DeepEqual(s.replayed(), expect) {
+		t.Fatalf("bad session state: %v", s.replayed())
+	}
+
+	// only the last USE and SET of each variable are kept, however many run.
+	for i := 0; i < 1000; i++ {
+		s.track(fmt.Sprintf("SET @@x = %d;", i), nil)
+	}
+	s.track("use test3", nil)
+	s.track("SET @@session.tidb_txn_mode='optimistic'", nil)
+	s.track("set names utf8mb4", nil)
+	expect := []string{"SET @@x = 999;", "use test3", "SET @@session.tidb_txn_mode='optimistic'", "set names utf8mb4"}
+	if !reflect.DeepEqual(s.replayed(), expect) {
+		t.Fatalf("bad session state: %v", s.replayed())
+	}
+}
+
+func TestStateKey(t *testing.T) {
+	cases := map[string]string{
+		"use test":                                  "use",
+		"set @@tidb_txn_mode = 'pessimistic'":       "tidb_txn_mode",
+		"set @@session.tidb_txn_mode='optimistic'":  "tidb_txn_mode",
+		"set session tidb_txn_mode = 'optimistic'":  "tidb_txn_mode",
+		"set tidb_txn_mode = 'optimistic'":          "tidb_txn_mode",
+		"set @a := 1":                               "@a",
+		"set names utf8mb4":                         "names",
+		"set @@global.tidb_enable_async_commit = 1": "global.tidb_enable_async_commit",
+	}
+	for q, key := range cases {
+		if k := stateKey(q); k != key {
+			t.Fatalf("bad key of %s: %s, expect %s", q, k, key)
+		}
+	}
+}
@@ -1,14 +1,15 @@
 package ddl
 
 import (
+	"concurrent-sql/client"
 	"concurrent-sql/util"
-	"database/sql"
 	"log"
 )
 
 type DDL struct {
 	Queries []string
-	DB      *sql.DB
+	Session *client.Session // all queries run on it, in order.
+	Log     *log.Logger
 }
 
 func (d *DDL) Load(path string) (err error) {
@@ -16,10 +17,15 @@ func (d *DDL) Load(path string) (err error) {
 	return err
 }
 
+// UseDatabase rewrites the queries naming database from to name database to.
+func (d *DDL) UseDatabase(from, to string) error {
+	return util.RenameDatabaseInAll(d.Queries, from, to)
+}
+
 func (d *DDL) Run() error {
 	for _, q := range d.Queries {
-		if _, err := d.DB.Exec(q); err != nil {
-			log.Println("error encountered ", err)
+		if _, err := d.Session.Exec(q); err != nil {
+			util.Logger(d.Log).Println("error encountered ", err)
 			return err
 		}
 	}
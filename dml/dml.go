@@ -1,54 +1,298 @@
 package dml
 
 import (
+	"concurrent-sql/client"
 	"concurrent-sql/util"
-	"database/sql"
 	"fmt"
 	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 )
 
 type DML struct {
-	SQLs    []string
-	Repeats int
-	DSN     string
+	Path         string
+	SQLs         []string
+	Transactions []util.Transaction // of the SQLs, each one is run as a whole.
+	Repeats      int
+	DSN          string
+	Tolerate     []uint16      // error codes counted instead of failing, like 1062 or 9007.
+	MaxRetries   int           // times a failed transaction is run again.
+	RetryOn      []uint16      // error codes of the transactions run again.
+	Transfer     *Transfer     // run the built-in transfer workload instead of the SQLs.
+	Workers      int           // concurrent connections running the SQLs, 1 if not positive.
+	Duration     time.Duration // run until it passes instead of Repeats times, if positive.
+	QPS          float64       // the statements per second of all workers, no limit if not positive.
+	Seed         int64         // of the random values of the workers.
+	Log          *log.Logger
+
+	// the last value of {{seq}}.
+	seq int64
+
+	// counts of all workers.
+	mu         sync.Mutex
+	Iterations int
+	Errors     map[uint16]int // tolerated errors by code.
+	Reconnects int            // of the sessions after their connection broke.
+	Commits    int            // of the transactions.
+	Rollbacks  int            // of the transactions, by ROLLBACK or after an error.
+	Retries    int            // of the failed transactions.
 }
 
 func (d *DML) Load(path string) (err error) {
-	d.SQLs, err = util.GetSQLStatements(path)
+	d.Path = path
+	d.SQLs, d.Transactions, err = util.GetSQLTransactions(path)
 	return err
 }
 
+// UseDatabase rewrites the SQLs naming database from to name database to.
+func (d *DML) UseDatabase(from, to string) error {
+	return util.RenameDatabaseInAll(d.SQLs, from, to)
+}
+
+// whether the error is one of the tolerated, and count it if so.
+func (d *DML) tolerate(err error) bool {
+	code, ok := util.ErrorCode(err)
+	if !ok {
+		return false
+	}
+	for _, c := range d.Tolerate {
+		if c == code {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			if d.Errors == nil {
+				d.Errors = make(map[uint16]int)
+			}
+			d.Errors[code]++
+			return true
+		}
+	}
+	return false
+}
+
+// whether the error is a broken connection, which does not fail the worker: the
+// session reconnects at the next statement, and the reconnects are counted.
+func (d *DML) lostConnection(err error) bool {
+	if !client.IsBadConn(err) {
+		return false
+	}
+	d.logger().Printf("dml %s: connection broken: %v", d.Path, err)
+	return true
+}
+
+// add n to one of the counts.
+func (d *DML) add(count *int, n int) {
+	d.mu.Lock()
+	*count += n
+	d.mu.Unlock()
+}
+
+// Summary prints the iterations and the tolerated errors, sample: 100 iterations, tolerated errors: 1062 x3, 9007 x1
+// The transactions are counted if any ran, sample: 100 iterations, 95 commits, 5 rollbacks, 12 retries
+func (d *DML) Summary() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	summary := fmt.Sprintf("%d iterations", d.Iterations)
+	if d.Commits+d.Rollbacks > 0 {
+		summary += fmt.Sprintf(", %d commits, %d rollbacks, %d retries", d.Commits, d.Rollbacks, d.Retries)
+	}
+	if d.Reconnects > 0 {
+		summary += fmt.Sprintf(", %d reconnects", d.Reconnects)
+	}
+	if len(d.Errors) == 0 {
+		return summary
+	}
+
+	codes := make([]int, 0, len(d.Errors))
+	for code := range d.Errors {
+		codes = append(codes, int(code))
+	}
+	sort.Ints(codes)
+	var counts []string
+	for _, code := range codes {
+		counts = append(counts, fmt.Sprintf("%d x%d", code, d.Errors[uint16(code)]))
+	}
+	return summary + ", tolerated errors: " + strings.Join(counts, ", ")
+}
+
+func (d *DML) logger() *log.Logger {
+	return util.Logger(d.Log)
+}
+
+// RunAsync runs the DML on Workers connections at the same time, each one
+// repeats it Repeats times, or until Duration passes. The errors are sent to c,
+// which is closed when all workers are done.
 func (d *DML) RunAsync(c chan string, shutdown chan struct{}) {
 	defer close(c)
+	defer func() {
+		d.logger().Printf("dml %s done: %s", d.Path, d.Summary())
+	}()
+
+	workers := d.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	limit := newLimiter(d.QPS)
+	defer limit.stop()
+	var deadline time.Time
+	if d.Duration > 0 {
+		deadline = time.Now().Add(d.Duration)
+	}
 
-	db, err := sql.Open("mysql", d.DSN)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			if errStr := d.runWorker(w, deadline, limit, shutdown); errStr != "" {
+				c <- errStr
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+// whether a worker runs the DML again after i runs.
+func (d *DML) more(i int, deadline time.Time) bool {
+	if deadline.IsZero() {
+		return i < d.Repeats
+	}
+	return time.Now().Before(deadline)
+}
+
+// run the repeats of one worker on a session of its own, or until the
+// deadline if it is set, returns the error.
+func (d *DML) runWorker(worker int, deadline time.Time, limit *limiter, shutdown chan struct{}) string {
+	session, err := client.NewSession(d.DSN)
 	if err != nil {
-		c <- fmt.Sprintf("bad database connection: %s, %s", err, d.DSN)
-		return
-	} else if err = db.Ping(); err != nil {
-		log.Println(err)
-		c <- fmt.Sprintf("ping db error, %s", err)
-		_ = db.Close()
-		return
+		d.logger().Println(err)
+		return fmt.Sprintf("connect db error, %s", err)
 	}
+	session.Log = d.Log
 	defer func() {
-		_ = db.Close()
+		_ = session.Close()
+		d.add(&d.Reconnects, session.Reconnects)
 	}()
 
-	for i := 0; i < d.Repeats; i++ {
+	if d.Transfer != nil {
+		if err := d.Transfer.check(); err != nil {
+			return err.Error()
+		}
+	}
+	r := d.newRand(worker)
+	rd, err := d.newRenderer(worker, r)
+	if err != nil {
+		return err.Error()
+	}
+	// every statement waits for its turn, and none starts after the deadline.
+	wait := func() bool {
+		return limit.wait(deadline, shutdown)
+	}
+	for i := 0; d.more(i, deadline); i++ {
 		select {
 		case <-shutdown:
-			return
+			return ""
 		default:
 		}
 
-		for _, q := range d.SQLs {
-			if _, err := db.Exec(q); err != nil {
+		if d.Transfer != nil {
+			err := d.runTransaction(session, d.Transfer.statements(r), false, wait)
+			if err == errStopped {
+				return ""
+			}
+			if err != nil && !d.tolerate(err) && !d.lostConnection(err) {
+				errStr := fmt.Sprintf("transfer error: %s", err)
+				d.logger().Println(errStr)
+				return errStr
+			}
+			d.add(&d.Iterations, 1)
+			continue
+		}
+
+		txns := d.Transactions
+		for j := 0; j < len(d.SQLs); j++ {
+			// the statements up to the end of the transaction begun by this one, if any.
+			end, rollback := j, false
+			if len(txns) > 0 && txns[0].Begin == j {
+				end, rollback = txns[0].End, txns[0].Rollback
+				txns = txns[1:]
+			}
+			queries := make([]string, 0, end-j+1)
+			for k := j; k <= end; k++ {
+				q, err := rd.render(k, d.SQLs[k])
+				if err != nil {
+					errStr := fmt.Sprintf("sql template error: %s", err)
+					d.logger().Println(errStr)
+					return errStr
+				}
+				queries = append(queries, q)
+			}
+
+			if end > j {
+				err = d.runTransaction(session, queries, rollback, wait)
+				j = end
+			} else if !wait() {
+				return ""
+			} else {
+				_, err = session.Exec(queries[0])
+			}
+			if err == errStopped {
+				return ""
+			}
+			if err != nil {
+				if d.tolerate(err) || d.lostConnection(err) {
+					continue
+				}
 				errStr := fmt.Sprintf("sql execute error: %s", err)
-				log.Println(errStr)
-				c <- fmt.Sprintf(errStr)
-				return
+				d.logger().Println(errStr)
+				return errStr
 			}
 		}
+		d.add(&d.Iterations, 1)
+	}
+	return ""
+}
+
+// run the statements of a transaction, from its BEGIN to its COMMIT or
+// ROLLBACK, on the session. A failed transaction is rolled back, and run again
+// up to MaxRetries times if the error is one of RetryOn. wait is called before
+// each statement, the transaction is stopped with errStopped if it returns false.
+func (d *DML) runTransaction(s *client.Session, queries []string, rollback bool, wait func() bool) error {
+	for retries := 0; ; retries++ {
+		err := d.execTransaction(s, queries, wait)
+		if err == errStopped {
+			return err
+		}
+		if err == nil {
+			if rollback {
+				d.add(&d.Rollbacks, 1)
+			} else {
+				d.add(&d.Commits, 1)
+			}
+			return nil
+		}
+		d.add(&d.Rollbacks, 1)
+		if retries >= d.MaxRetries || !util.HasErrorCode(err, d.RetryOn) {
+			return err
+		}
+		d.add(&d.Retries, 1)
+	}
+}
+
+// run the statements of a transaction once, it is rolled back on error, so the
+// next BEGIN does not commit what ran of it. A failed COMMIT is rolled back by
+// the server, the ROLLBACK does nothing then.
+func (d *DML) execTransaction(s *client.Session, queries []string, wait func() bool) error {
+	for _, q := range queries {
+		if !wait() {
+			_, _ = s.Exec("ROLLBACK")
+			return errStopped
+		}
+		if _, err := s.Exec(q); err != nil {
+			_, _ = s.Exec("ROLLBACK")
+			return err
+		}
 	}
+	return nil
 }
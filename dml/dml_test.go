@@ -0,0 +1,54 @@
+package dml
+
+import (
+	"database/sql/driver"
+	"errors"
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestDML_lostConnection(t *testing.T) {
+	d := &DML{Path: "a.sql", Log: log.New(ioutil.Discard, "", 0)}
+	errs := []struct {
+		err  error
+		lost bool
+	}{
+		{driver.ErrBadConn, true},
+		{mysql.ErrInvalidConn, true},
+		{&mysql.MySQLError{Number: 9007, Message: "write conflict"}, false},
+		{errors.New("dial tcp 127.0.0.1:4000: connect: connection refused"), false},
+	}
+	for _, e := range errs {
+		if d.lostConnection(e.err) != e.lost {
+			t.Errorf("lost connection of %v should be %v", e.err, e.lost)
+		}
+	}
+}
+
+func TestLimiter_Wait(t *testing.T) {
+	shutdown := make(chan struct{})
+	var unlimited *limiter
+	if !unlimited.wait(time.Time{}, shutdown) || unlimited.wait(time.Now().Add(-time.Second), shutdown) {
+		t.Fatalf("an unlimited worker only stops at the deadline")
+	}
+
+	// a statement every 10s does not hold the worker past the deadline.
+	limit := newLimiter(0.1)
+	defer limit.stop()
+	start := time.Now()
+	if limit.wait(start.Add(50*time.Millisecond), shutdown) {
+		t.Fatalf("the turn came before the deadline")
+	}
+	if waited := time.Since(start); waited > 5*time.Second {
+		t.Fatalf("waited %s past the deadline", waited)
+	}
+
+	close(shutdown)
+	if limit.wait(time.Time{}, shutdown) || unlimited.wait(time.Time{}, shutdown) {
+		t.Fatalf("a worker waits after shutdown")
+	}
+}
@@ -0,0 +1,63 @@
+package dml
+
+import (
+	"errors"
+	"time"
+)
+
+// errStopped ends a transaction which ran out of time or was shut down before
+// its next statement, it is rolled back.
+var errStopped = errors.New("dml stopped")
+
+// limiter paces the statements of all workers of a DML file to a rate, a nil
+// limiter does not wait.
+type limiter struct {
+	ticker *time.Ticker
+}
+
+func newLimiter(qps float64) *limiter {
+	if qps <= 0 {
+		return nil
+	}
+	interval := time.Duration(float64(time.Second) / qps)
+	if interval <= 0 {
+		interval = 1
+	}
+	return &limiter{ticker: time.NewTicker(interval)}
+}
+
+// wait for the turn of the next statement, returns false if shutdown or the
+// deadline passes first. A zero deadline never passes.
+func (l *limiter) wait(deadline time.Time, shutdown chan struct{}) bool {
+	if !deadline.IsZero() && !time.Now().Before(deadline) {
+		return false
+	}
+	if l == nil {
+		select {
+		case <-shutdown:
+			return false
+		default:
+			return true
+		}
+	}
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-l.ticker.C:
+		return true
+	case <-shutdown:
+		return false
+	case <-timeout:
+		return false
+	}
+}
+
+func (l *limiter) stop() {
+	if l != nil {
+		l.ticker.Stop()
+	}
+}
@@ -0,0 +1,92 @@
+package dml
+
+import (
+	"bytes"
+	"concurrent-sql/util"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"text/template"
+)
+
+const randStringLetters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// renderer expands the placeholders of the SQLs for one worker, each execution
+// gets new values:
+//
+//	{{randInt 1 1000}} a random integer in [1, 1000]
+//	{{seq}}            the next number of a sequence shared by the workers of the file, from 1
+//	{{randString 16}}  a random string of 16 letters and digits
+//	{{pick "a" "b"}}   one of the arguments at random
+//	{{worker}}         the index of the worker, from 0
+type renderer struct {
+	templates []*template.Template // nil for a statement without placeholders.
+}
+
+// the random source of a worker, the same seed gives the same values in each run.
+func (d *DML) newRand(worker int) *rand.Rand {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(d.Path))
+	return rand.New(rand.NewSource(d.Seed + int64(h.Sum64()) + int64(worker)))
+}
+
+func (d *DML) newRenderer(worker int, r *rand.Rand) (*renderer, error) {
+	funcs := template.FuncMap{
+		"randInt": func(min, max int64) (int64, error) {
+			if max < min {
+				return 0, fmt.Errorf("randInt: max %d is less than min %d", max, min)
+			}
+			return min + r.Int63n(max-min+1), nil
+		},
+		"seq": func() int64 {
+			return atomic.AddInt64(&d.seq, 1)
+		},
+		"randString": func(n int) string {
+			b := make([]byte, n)
+			for i := range b {
+				b[i] = randStringLetters[r.Intn(len(randStringLetters))]
+			}
+			return string(b)
+		},
+		"pick": func(values ...interface{}) (interface{}, error) {
+			if len(values) == 0 {
+				return nil, errors.New("pick: no values")
+			}
+			return values[r.Intn(len(values))], nil
+		},
+		"worker": func() int {
+			return worker
+		},
+	}
+
+	rd := &renderer{}
+	for i, q := range d.SQLs {
+		if !util.HasTemplate(q) {
+			rd.templates = append(rd.templates, nil)
+			continue
+		}
+		t, err := template.New(strconv.Itoa(i)).Funcs(funcs).Parse(q)
+		if err != nil {
+			return nil, fmt.Errorf("bad template %s: %v", strings.TrimSpace(q), err)
+		}
+		rd.templates = append(rd.templates, t)
+	}
+	return rd, nil
+}
+
+// render the i-th SQL with new values.
+func (rd *renderer) render(i int, q string) (string, error) {
+	t := rd.templates[i]
+	if t == nil {
+		return q, nil
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, nil); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
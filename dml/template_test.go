@@ -0,0 +1,55 @@
+package dml
+
+import (
+	"regexp"
+	"testing"
+)
+
+func renderAll(t *testing.T, d *DML, worker int) []string {
+	rd, err := d.newRenderer(worker, d.newRand(worker))
+	if err != nil {
+		t.Fatalf("bad templates: %v", err)
+	}
+	var queries []string
+	for i, q := range d.SQLs {
+		q, err := rd.render(i, q)
+		if err != nil {
+			t.Fatalf("render failed: %v", err)
+		}
+		queries = append(queries, q)
+	}
+	return queries
+}
+
+func TestRenderer(t *testing.T) {
+	sqls := []string{
+		"insert into t values ({{seq}}, {{randInt 1 1000}}, '{{randString 16}}', '{{pick \"a\" \"b\"}}', {{worker}})",
+		"delete from t where id = 1",
+	}
+	pattern := regexp.MustCompile(`^insert into t values \(1, [0-9]+, '[a-zA-Z0-9]{16}', '[ab]', 3\)$`)
+
+	d := &DML{Path: "a.sql", SQLs: sqls, Seed: 42}
+	first := renderAll(t, d, 3)
+	if !pattern.MatchString(first[0]) || first[1] != sqls[1] {
+		t.Fatalf("bad render: %q", first)
+	}
+
+	// the same seed gives the same values, another seed others.
+	again := renderAll(t, &DML{Path: "a.sql", SQLs: sqls, Seed: 42}, 3)
+	if again[0] != first[0] {
+		t.Fatalf("not reproducible: %s, %s", first[0], again[0])
+	}
+	other := renderAll(t, &DML{Path: "a.sql", SQLs: sqls, Seed: 43}, 3)
+	if other[0] == first[0] {
+		t.Fatalf("seed ignored: %s", other[0])
+	}
+
+	// the sequence continues over workers.
+	if next := renderAll(t, d, 0); next[0][len("insert into t values ("):][0] != '2' {
+		t.Fatalf("bad sequence: %s", next[0])
+	}
+
+	if _, err := (&DML{SQLs: []string{"select {{nope}}"}}).newRenderer(0, d.newRand(0)); err == nil {
+		t.Fatalf("unknown function accepted")
+	}
+}
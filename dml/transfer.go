@@ -0,0 +1,48 @@
+package dml
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// TransferFile is the file name of the built-in transfer workload in the [DML] section.
+const TransferFile = "@transfer"
+
+// Transfer is a built-in workload moving random amounts between two random
+// accounts in one transaction, so the total balance never changes. The table
+// needs an id column numbered from 1 to Accounts and a balance column.
+type Transfer struct {
+	Table     string
+	Accounts  int
+	MaxAmount int
+}
+
+func NewTransfer() *Transfer {
+	return &Transfer{Table: "accounts", Accounts: 10, MaxAmount: 100}
+}
+
+func (t *Transfer) check() error {
+	if t.Accounts < 2 {
+		return fmt.Errorf("transfer needs at least 2 accounts, got %d", t.Accounts)
+	}
+	if t.MaxAmount < 1 {
+		return fmt.Errorf("transfer needs a positive amount, got %d", t.MaxAmount)
+	}
+	return nil
+}
+
+// the statements of one transfer transaction, from and to are different accounts.
+func (t *Transfer) statements(r *rand.Rand) []string {
+	from := r.Intn(t.Accounts) + 1
+	to := r.Intn(t.Accounts-1) + 1
+	if to >= from {
+		to++
+	}
+	amount := r.Intn(t.MaxAmount) + 1
+	return []string{
+		"BEGIN",
+		fmt.Sprintf("UPDATE `%s` SET balance = balance - %d WHERE id = %d", t.Table, amount, from),
+		fmt.Sprintf("UPDATE `%s` SET balance = balance + %d WHERE id = %d", t.Table, amount, to),
+		"COMMIT",
+	}
+}
@@ -1,20 +1,58 @@
 package main
 
 import (
+	"concurrent-sql/report"
 	"concurrent-sql/tests"
 	"concurrent-sql/verify"
 	"database/sql"
 	"flag"
 	"fmt"
 	_ "github.com/go-sql-driver/mysql"
+	"io"
 	"log"
+	"os"
 	"strings"
+	"text/tabwriter"
+	"time"
 )
 
 var paramDir = flag.String("dir", "test-cases", "specify the test case directory")
 var genExpect = flag.Bool("gen", false, "generate a expect result of specified query")
 var dsn = flag.String("dsn", "root@tcp(127.0.0.1:4000)/?allowNativePasswords=true&maxAllowedPacket=0", "db connection")
 var query = flag.String("query", "", "specify the query to be execute to get the expect result string")
+var parallel = flag.Int("parallel", 1, "how many cases run at the same time")
+var failFast = flag.Bool("fail-fast", false, "stop at the first failed case, the cases not started yet are not run")
+var expectFile = flag.String("expect-file", "", "write the generated expect raw to this file, for expect_file, instead of printing it")
+var normalize = flag.Bool("normalize", false, "strip operator ids from the generated expect, for the explain assert")
+var record = flag.Bool("record", false, "run the cases and fill in the empty expects of the verify files with the actual results")
+var update = flag.Bool("update", false, "run the cases and rewrite every expect of the verify files with the actual results")
+var seed = flag.Int64("seed", 0, "seed of the random values of the dml templates, 0 for a random one which is logged")
+var reports reportOptions
+
+func init() {
+	flag.Var(&reports, "report", "write a report of the run as format=path, format is junit, json or html, can be repeated")
+}
+
+// reportOptions are the values of the repeated -report flag.
+type reportOptions []string
+
+func (r *reportOptions) String() string {
+	return strings.Join(*r, ",")
+}
+
+func (r *reportOptions) Set(option string) error {
+	if _, _, err := report.ParseOutput(option); err != nil {
+		return err
+	}
+	*r = append(*r, option)
+	return nil
+}
+
+// exit codes.
+const (
+	exitFailed = 1 // a case failed.
+	exitError  = 2 // the cases could not be loaded, or the reports not written.
+)
 
 func main() {
 
@@ -28,25 +66,120 @@ func main() {
 	log.Printf("begin test")
 	log.Printf("dir=%s", *paramDir)
 
+	recordMode := verify.RecordNone
+	if *update {
+		recordMode = verify.RecordAll
+	} else if *record {
+		recordMode = verify.RecordMissing
+	}
+
 	var testCases []*tests.TestCase
-	if cases, err := tests.LoadCases(*paramDir); err != nil {
-		log.Fatal(err)
+	if cases, err := tests.LoadCases(*paramDir, recordMode); err != nil {
+		log.Println("load cases failed: ", err)
+		os.Exit(exitError)
 	} else {
 		testCases = cases
 		log.Printf("%d cases loaded", len(testCases))
 	}
 
-	// 2. invoke each case's run.
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	log.Printf("seed=%d", *seed)
 	for _, c := range testCases {
-		if err := c.Run(); err != nil {
-			// error happened.
-			log.Fatal(err)
+		c.SetSeed(*seed)
+	}
+
+	// 2. invoke each case's run.
+	results := tests.RunCases(testCases, *parallel, *failFast)
+	passed := printSummary(os.Stdout, len(testCases), results)
+	if recordMode != verify.RecordNone {
+		if err := writeExpects(os.Stdout, results); err != nil {
+			log.Println("write expects failed: ", err)
+			os.Exit(exitError)
+		}
+	}
+	if len(reports) > 0 {
+		r := report.New(results)
+		for _, option := range reports {
+			format, path, _ := report.ParseOutput(option)
+			if err := report.Write(r, format, path); err != nil {
+				log.Printf("write %s report failed: %v", format, err)
+				os.Exit(exitError)
+			}
+			log.Printf("%s report written to %s", format, path)
 		}
 	}
+	if !passed {
+		log.Printf("test failed")
+		os.Exit(exitFailed)
+	}
 
 	log.Printf("test finish")
 }
 
+// print a table of the case runs, with the phase and assert a case failed at,
+// returns whether all cases passed.
+func printSummary(out io.Writer, total int, results []*tests.CaseResult) bool {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CASE\tRESULT\tPHASE\tASSERT\tDURATION")
+	passed := 0
+	for _, r := range results {
+		status := "PASS"
+		if r.Err != nil {
+			status = "FAIL"
+		} else {
+			passed++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Case.Name(), status, r.Phase(), r.Location(), r.Duration.Round(time.Millisecond))
+	}
+	_ = w.Flush()
+	fmt.Fprintf(out, "%d cases, %d passed, %d failed, %d not run\n", total, passed, len(results)-passed, total-len(results))
+	return passed == total
+}
+
+// rewrite the expects recorded in the verify files, and print which ones changed.
+// Nothing is written if the variants of a [Matrix] case recorded different results.
+// The failed cases record nothing.
+func writeExpects(out io.Writer, results []*tests.CaseResult) error {
+	for _, r := range results {
+		if r.Err != nil && r.Case.VerificationFile != "" {
+			fmt.Fprintf(out, "%s: not recorded, the case failed\n", r.Case.Name())
+		}
+	}
+	files, updates, conflicts := tests.RecordedUpdates(results)
+	if len(conflicts) > 0 {
+		for _, c := range conflicts {
+			fmt.Fprintln(out, c.Error())
+		}
+		return fmt.Errorf("%d expects recorded differently by the variants of a case, no expect written", len(conflicts))
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tASSERT\tCHANGE")
+	changed := 0
+	for _, verifyFile := range files {
+		if err := verify.UpdateVerificationFile(verifyFile, updates[verifyFile]); err != nil {
+			return err
+		}
+		for _, u := range updates[verifyFile] {
+			change := "updated"
+			if u.Old == "" {
+				change = "added"
+			}
+			file := verifyFile
+			if u.File != "" {
+				file = u.File
+			}
+			fmt.Fprintf(w, "%s\tverify[%d].asserts[%d]\t%s\n", file, u.Verify, u.Assert, change)
+		}
+		changed += len(updates[verifyFile])
+	}
+	_ = w.Flush()
+	fmt.Fprintf(out, "%d expects changed\n", changed)
+	return nil
+}
+
 func printExpectResult(dsn, query string) {
 	db, err := sql.Open("mysql", dsn)
 	if err != nil {
@@ -57,6 +190,16 @@ func printExpectResult(dsn, query string) {
 		log.Fatal("query from failed", err)
 	}
 	str := result.ToOneString()
+	if *normalize {
+		str = result.ToNormalizedString()
+	}
+	if *expectFile != "" {
+		if err := verify.WriteExpectFile(*expectFile, str); err != nil {
+			log.Fatal("write expect file failed", err)
+		}
+		log.Printf("expect written to %s", *expectFile)
+		return
+	}
 	str = strings.ReplaceAll(str, "\n", "\\n")
 	str = strings.ReplaceAll(str, "\t", "\\t")
 	fmt.Println(str)
@@ -0,0 +1,117 @@
+package report
+
+import (
+	"concurrent-sql/verify"
+	"html/template"
+	"io"
+	"os"
+)
+
+// the diff of the expect and the actual result, marked up like the terminal diff.
+type htmlDiff struct {
+	Expect, Actual template.HTML
+}
+
+// Diff of a failed assert for the page, nil if there is nothing to diff. Only
+// the expects in the format of the result are diffed, not the patterns, plan
+// checks and cardinality limits, their failure message tells what is wrong.
+func (a *Assert) Diff() *htmlDiff {
+	if a.Status != StatusFailed || a.Expect == "" || !diffable(a.Type) {
+		return nil
+	}
+	mark := func(class string) func(string) string {
+		return func(s string) string {
+			return `<span class="` + class + `">` + template.HTMLEscapeString(s) + `</span>`
+		}
+	}
+	expect, actual := verify.DiffSides(a.Expect, a.Actual, template.HTMLEscapeString, mark("del"), mark("ins"))
+	return &htmlDiff{Expect: template.HTML(expect), Actual: template.HTML(actual)}
+}
+
+// whether the expects of the assert type are compared as text with the result.
+func diffable(assertType string) bool {
+	switch assertType {
+	case verify.ASSERT_TYPE_PLAN, verify.ASSERT_TYPE_PLAN_MATCH, verify.ASSERT_TYPE_CARDINALITY,
+		verify.ASSERT_TYPE_ADMIN, verify.ASSERT_TYPE_CONSISTENCY:
+		return false
+	}
+	return true
+}
+
+// counts of the cases by status.
+func (r *Report) Passed() int {
+	n := 0
+	for _, c := range r.Cases {
+		if c.Passed {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *Report) Failed() int {
+	return len(r.Cases) - r.Passed()
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"seconds": seconds,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>concurrent-sql report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+pre { background: #f6f8fa; padding: .5em; overflow-x: auto; margin: .3em 0; }
+details { margin: .3em 0; }
+summary { cursor: pointer; }
+table { border-collapse: collapse; }
+td { border-top: 1px solid #ddd; padding: .3em .6em; vertical-align: top; }
+.passed { color: #1a7f37; } .failed, .error { color: #cf222e; } .skipped { color: #6e7781; }
+.del { background: #ffd7d5; } .ins { background: #ccffd8; }
+.diff { display: flex; gap: 1em; } .diff > div { flex: 1; min-width: 0; }
+.plan details, .plan .leaf { margin-left: 1.2em; font-family: monospace; }
+.plan .attr { color: #6e7781; }
+</style>
+</head>
+<body>
+<h1>concurrent-sql report</h1>
+<p>{{len .Cases}} cases, <span class="passed">{{.Passed}} passed</span>, <span class="failed">{{.Failed}} failed</span></p>
+{{range .Cases}}
+<details{{if not .Passed}} open{{end}}>
+<summary><b>{{.Name}}</b> <span class="{{if .Passed}}passed">PASS{{else}}failed">FAIL{{end}}</span> {{seconds .Time}}s</summary>
+{{if .Error}}<pre class="error">{{.Error}}</pre>{{end}}
+<table>
+{{range .Asserts}}
+<tr>
+<td>{{.Name}}</td><td>{{.Type}}</td><td class="{{.Status}}">{{.Status}}</td><td>{{seconds .Time}}s</td>
+<td>
+<pre>{{.SQL}}</pre>
+{{with .Diff}}<div class="diff"><div>Expected<pre>{{.Expect}}</pre></div><div>Actual<pre>{{.Actual}}</pre></div></div>{{end}}
+{{if .Failure}}<details><summary>failure</summary><pre>{{.Failure}}</pre></details>{{end}}
+{{if .Error}}<pre class="error">{{.Error}}</pre>{{end}}
+{{if .Plan}}<details class="plan"{{if ne .Status "passed"}} open{{end}}><summary>plan</summary>{{template "node" .Plan}}</details>
+{{else if .Output}}<details><summary>result</summary><pre>{{.Output}}</pre></details>{{end}}
+</td>
+</tr>
+{{end}}
+</table>
+</details>
+{{end}}
+</body>
+</html>
+{{define "node"}}{{if .Children}}<details open><summary>{{template "operator" .}}</summary>{{range .Children}}{{template "node" .}}{{end}}</details>{{else}}<div class="leaf">{{template "operator" .}}</div>{{end}}{{end}}
+{{define "operator"}}<b>{{.ID}}</b> <span class="attr">{{.EstRows}}{{if .ActRows}} act:{{.ActRows}}{{end}} {{.Task}}</span> {{.AccessObject}} {{.OperatorInfo}}{{end}}
+`))
+
+func writeHTML(w io.Writer, r *Report) error {
+	return htmlTemplate.Execute(w, r)
+}
+
+// WriteHTML writes the report as a single static HTML page, with the diff of
+// each failed assert and the plan of each EXPLAIN as a collapsible tree.
+func WriteHTML(r *Report, path string) error {
+	return createFile(path, func(f *os.File) error {
+		return writeHTML(f, r)
+	})
+}
@@ -0,0 +1,15 @@
+package report
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// WriteJSON writes the report as indented JSON.
+func WriteJSON(r *Report, path string) error {
+	return createFile(path, func(f *os.File) error {
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(r)
+	})
+}
@@ -0,0 +1,109 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+)
+
+// the JUnit XML format, as read by Jenkins and most CI servers.
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut *junitOutput  `xml:"system-out,omitempty"`
+}
+
+type junitOutput struct {
+	Text string `xml:",cdata"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+	Text    string `xml:",cdata"`
+}
+
+// the details of an assert: the sql, the expect and the actual result.
+func (a *Assert) details() string {
+	return fmt.Sprintf("SQL:\n%s\nExpect:\n%s\nActual:\n%s\n", a.SQL, a.Expect, a.Actual)
+}
+
+func (c *Case) junit() junitSuite {
+	suite := junitSuite{Name: c.Name, Time: seconds(c.Time)}
+	// a failure outside of the asserts, like a ddl error, is a test case of its own.
+	if !c.Passed && !c.hasFailedAssert() {
+		suite.Cases = append(suite.Cases, junitCase{
+			Name:      c.Phase,
+			ClassName: c.Name,
+			Time:      seconds(0),
+			Error:     &junitMessage{Message: c.Phase + " failed", Text: c.Error},
+		})
+		suite.Errors++
+	}
+	for _, a := range c.Asserts {
+		tc := junitCase{
+			Name:      fmt.Sprintf("%s %s", a.Name, a.Type),
+			ClassName: c.Name,
+			Time:      seconds(a.Time),
+			SystemOut: &junitOutput{Text: a.details()},
+		}
+		switch a.Status {
+		case StatusFailed:
+			tc.Failure = &junitMessage{Message: "result is not the expected one", Text: a.Failure}
+			suite.Failures++
+		case StatusError:
+			tc.Error = &junitMessage{Message: a.Error, Text: a.Error}
+			suite.Errors++
+		case StatusSkipped:
+			tc.Skipped = &junitMessage{Message: "not run"}
+			suite.Skipped++
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	suite.Tests = len(suite.Cases)
+	return suite
+}
+
+func writeJUnit(w io.Writer, r *Report) error {
+	suites := junitSuites{}
+	for _, c := range r.Cases {
+		suites.Suites = append(suites.Suites, c.junit())
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// WriteJUnit writes the report as JUnit XML, each case is a test suite and each
+// assert a test case.
+func WriteJUnit(r *Report, path string) error {
+	return createFile(path, func(f *os.File) error {
+		return writeJUnit(f, r)
+	})
+}
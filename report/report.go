@@ -0,0 +1,174 @@
+// Package report writes the results of a run of test cases in machine-readable
+// formats, one of Formats.
+package report
+
+import (
+	"concurrent-sql/tests"
+	"concurrent-sql/verify"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// the status of an assert.
+const (
+	StatusPassed  = "passed"
+	StatusFailed  = "failed"  // the result is not the expected one.
+	StatusError   = "error"   // the sql failed.
+	StatusSkipped = "skipped" // not run, the case failed before.
+)
+
+// Report is the results of all cases of a run.
+type Report struct {
+	Cases []*Case `json:"cases"`
+}
+
+// Case is the result of one case.ini, or of one combination of its [Matrix].
+type Case struct {
+	Name     string            `json:"name"` // the path, and the combination if any.
+	Path     string            `json:"path"`
+	Variant  map[string]string `json:"variant,omitempty"` // the values of the [Matrix].
+	Passed   bool              `json:"passed"`
+	Phase    string            `json:"phase,omitempty"`    // failed in.
+	Location string            `json:"location,omitempty"` // of the failing assert.
+	Error    string            `json:"error,omitempty"`
+	Time     float64           `json:"time"` // seconds.
+	Asserts  []*Assert         `json:"asserts"`
+}
+
+// Assert is the result of one assert of a verification.
+type Assert struct {
+	Name    string  `json:"name"` // sample: verify[0].asserts[1]
+	Type    string  `json:"type,omitempty"`
+	SQL     string  `json:"sql"`
+	Status  string  `json:"status"`
+	Runs    int     `json:"runs"`
+	Time    float64 `json:"time"` // seconds of the last run.
+	Expect  string  `json:"expect,omitempty"`
+	Actual  string  `json:"actual,omitempty"`
+	Output  string  `json:"output,omitempty"` // the whole result of the sql.
+	Failure string  `json:"failure,omitempty"`
+	Error   string  `json:"error,omitempty"`
+
+	Plan *verify.PlanNode `json:"-"` // the operator tree of an EXPLAIN.
+}
+
+// whether the case failure is the failure of an assert.
+func (c *Case) hasFailedAssert() bool {
+	for _, a := range c.Asserts {
+		if a.Status == StatusFailed || a.Status == StatusError {
+			return true
+		}
+	}
+	return false
+}
+
+var colorPattern = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// remove the terminal colors of the diffs.
+func stripColors(s string) string {
+	return colorPattern.ReplaceAllString(s, "")
+}
+
+// New collects the report from the results of the cases.
+func New(results []*tests.CaseResult) *Report {
+	r := &Report{}
+	for _, result := range results {
+		c := &Case{
+			Name:     result.Case.Name(),
+			Path:     result.Case.Path,
+			Passed:   result.Err == nil,
+			Phase:    result.Phase(),
+			Location: result.Location(),
+			Time:     result.Duration.Seconds(),
+		}
+		for _, v := range result.Case.Variant {
+			if c.Variant == nil {
+				c.Variant = make(map[string]string)
+			}
+			c.Variant[v.Name] = v.Value
+		}
+		if result.Err != nil {
+			c.Error = stripColors(result.Err.Error())
+		}
+		for i, v := range result.Case.Verifications {
+			for j, as := range v.Asserts {
+				a := &Assert{
+					Name:   fmt.Sprintf("verify[%d].asserts[%d]", i, j),
+					Type:   as.Type,
+					SQL:    as.SQL,
+					Expect: as.Expect,
+					Status: StatusSkipped,
+				}
+				if j < len(v.Results) && v.Results[j].Runs > 0 {
+					res := v.Results[j]
+					a.Runs = res.Runs
+					a.Time = res.Duration.Seconds()
+					a.Actual = res.Actual
+					a.Output = res.Output
+					a.Plan = res.Plan
+					if res.Expect != "" {
+						a.Expect = res.Expect
+					}
+					a.Failure = stripColors(res.Failure)
+					a.Status = StatusPassed
+					if res.Err != nil {
+						a.Status, a.Error = StatusError, res.Err.Error()
+					} else if res.Failure != "" {
+						a.Status = StatusFailed
+					}
+				}
+				c.Asserts = append(c.Asserts, a)
+			}
+		}
+		r.Cases = append(r.Cases, c)
+	}
+	return r
+}
+
+// Formats are the report formats by name, each writes the report to a file.
+var Formats = map[string]func(r *Report, path string) error{
+	"junit": WriteJUnit,
+	"json":  WriteJSON,
+	"html":  WriteHTML,
+}
+
+// ParseOutput parses a report option, sample: junit=report.xml
+func ParseOutput(option string) (format, path string, err error) {
+	i := strings.Index(option, "=")
+	if i <= 0 || i == len(option)-1 {
+		return "", "", fmt.Errorf("bad report %q, expect format=path", option)
+	}
+	format, path = option[:i], option[i+1:]
+	if _, ok := Formats[format]; !ok {
+		return "", "", fmt.Errorf("unknown report format %q", format)
+	}
+	return format, path, nil
+}
+
+// Write the report to path in the format.
+func Write(r *Report, format, path string) error {
+	write, ok := Formats[format]
+	if !ok {
+		return fmt.Errorf("unknown report format %q", format)
+	}
+	return write(r, path)
+}
+
+func createFile(path string, write func(f *os.File) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// format seconds with millisecond precision.
+func seconds(s float64) string {
+	return fmt.Sprintf("%.3f", s)
+}
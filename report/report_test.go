@@ -0,0 +1,123 @@
+package report
+
+import (
+	"bytes"
+	"concurrent-sql/tests"
+	"concurrent-sql/util"
+	"concurrent-sql/verify"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sampleResults() []*tests.CaseResult {
+	passed := &tests.TestCase{Path: "cases/a", Verifications: []verify.Verify{{
+		Asserts: []verify.Assert{{Type: "plan", SQL: "explain select 1", Expect: "TableScan"}},
+		Results: []verify.AssertResult{{Runs: 1, Duration: time.Millisecond, Actual: "TableScan"}},
+	}}}
+	failed := &tests.TestCase{Path: "cases/b", Verifications: []verify.Verify{{
+		Asserts: []verify.Assert{
+			{Type: "result", SQL: "select 1", Expect: "2"},
+			{Type: "result", SQL: "select 2", Expect: "2"},
+		},
+		Results: []verify.AssertResult{{Runs: 1, Actual: "1", Failure: "Result is not equals to Expect\n\x1b[31m2\x1b[0m"}, {}},
+	}}}
+	ddlFailed := &tests.TestCase{Path: "cases/c", Variant: []util.Variable{{Name: "tidb_txn_mode", Value: "pessimistic"}}}
+	return []*tests.CaseResult{
+		{Case: passed, Duration: time.Second},
+		{Case: failed, Err: &tests.CaseError{Phase: tests.PhaseVerify, Verify: 0, Assert: 0, Err: &verify.AssertError{Index: 0}}},
+		{Case: ddlFailed, Err: &tests.CaseError{Phase: tests.PhaseDDL, Verify: -1, Assert: -1, Err: errors.New("bad sql")}},
+	}
+}
+
+func TestNew(t *testing.T) {
+	r := New(sampleResults())
+	if len(r.Cases) != 3 {
+		t.Fatalf("bad cases: %d", len(r.Cases))
+	}
+	if c := r.Cases[0]; !c.Passed || c.Time != 1 || c.Asserts[0].Status != StatusPassed || c.Asserts[0].Actual != "TableScan" {
+		t.Fatalf("bad passed case: %+v", c)
+	}
+	c := r.Cases[1]
+	if c.Passed || c.Location != "verify[0].asserts[0]" || c.Asserts[0].Status != StatusFailed || c.Asserts[1].Status != StatusSkipped {
+		t.Fatalf("bad failed case: %+v", c)
+	}
+	if c.Asserts[0].Failure != "Result is not equals to Expect\n2" {
+		t.Fatalf("colors not stripped: %q", c.Asserts[0].Failure)
+	}
+	if c := r.Cases[2]; c.Name != "cases/c[tidb_txn_mode=pessimistic]" || c.Path != "cases/c" || c.Variant["tidb_txn_mode"] != "pessimistic" {
+		t.Fatalf("bad variant case: %+v", c)
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeJUnit(&buf, New(sampleResults())); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	var suites junitSuites
+	if err := xml.Unmarshal(buf.Bytes(), &suites); err != nil {
+		t.Fatalf("bad xml: %v\n%s", err, buf.String())
+	}
+	expects := []struct {
+		tests, failures, errors, skipped int
+	}{{1, 0, 0, 0}, {2, 1, 0, 1}, {1, 0, 1, 0}}
+	for i, e := range expects {
+		s := suites.Suites[i]
+		if s.Tests != e.tests || s.Failures != e.failures || s.Errors != e.errors || s.Skipped != e.skipped {
+			t.Fatalf("bad suite %s: %+v", s.Name, s)
+		}
+	}
+	if tc := suites.Suites[2].Cases[0]; tc.Name != "DDL" || tc.Error == nil || tc.Error.Text != "DDL failed: bad sql" {
+		t.Fatalf("bad ddl failure: %+v", tc)
+	}
+}
+
+func TestParseOutput(t *testing.T) {
+	if format, path, err := ParseOutput("junit=out/report.xml"); err != nil || format != "junit" || path != "out/report.xml" {
+		t.Fatalf("parse failed: %s %s %v", format, path, err)
+	}
+	for _, option := range []string{"junit", "junit=", "=a.xml", "xml=a.xml"} {
+		if _, _, err := ParseOutput(option); err == nil {
+			t.Fatalf("bad option accepted: %s", option)
+		}
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	results := sampleResults()
+	plan := &verify.PlanNode{ID: "TableReader_5", EstRows: "10000.00", Task: "root", Children: []*verify.PlanNode{
+		{ID: "TableScan_4", EstRows: "10000.00", Task: "cop", AccessObject: "table:t<1>"},
+	}}
+	results[1].Case.Verifications[0].Results[0].Plan = plan
+	var buf bytes.Buffer
+	if err := writeHTML(&buf, New(results)); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	html := buf.String()
+
+	// a failed plan_match pattern is not diffed with the plan.
+	r := New(results)
+	matched := r.Cases[1].Asserts[0]
+	if matched.Diff() == nil {
+		t.Fatalf("a failed result assert should be diffed")
+	}
+	matched.Type = verify.ASSERT_TYPE_PLAN_MATCH
+	if matched.Diff() != nil {
+		t.Fatalf("a failed plan_match assert should not be diffed")
+	}
+	for _, expect := range []string{
+		`3 cases, <span class="passed">1 passed</span>, <span class="failed">2 failed</span>`,
+		`<pre><span class="del">2</span></pre>`,
+		`<pre><span class="ins">1</span></pre>`,
+		`<details open><summary><b>TableReader_5</b>`,
+		`<div class="leaf"><b>TableScan_4</b> <span class="attr">10000.00 cop</span> table:t&lt;1&gt;`,
+		`<pre class="error">DDL failed: bad sql</pre>`,
+	} {
+		if !strings.Contains(html, expect) {
+			t.Fatalf("%s not found in:\n%s", expect, html)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package script
+
+import (
+	"concurrent-sql/client"
+	"concurrent-sql/util"
+	"concurrent-sql/verify"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// StepError is the failure of a step, at its Location.
+type StepError struct {
+	Path string
+	Step Step
+	Err  error
+}
+
+// Location of the step, sample: lock-wait.script:4
+func (e *StepError) Location() string {
+	return fmt.Sprintf("%s:%d", path.Base(e.Path), e.Step.Line)
+}
+
+func (e *StepError) Error() string {
+	sql := e.Step.SQL
+	if sql == "" {
+		sql = "-- expect " + e.Step.Expect.String()
+	}
+	return fmt.Sprintf("%s: %s: %v", e.Step.Session, sql, e.Err)
+}
+
+// the outcome of a statement, result is "" if it failed.
+type outcome struct {
+	result string
+	err    error
+}
+
+func (o outcome) String() string {
+	if o.err != nil {
+		return fmt.Sprintf("error %v", o.err)
+	}
+	return fmt.Sprintf("result %q", o.result)
+}
+
+// check the outcome of a finished statement against the expect.
+func (e Expect) check(o outcome) error {
+	if e.Error {
+		if o.err == nil {
+			return fmt.Errorf("expect an error, got %s", o)
+		}
+		if code, _ := util.ErrorCode(o.err); e.Code != 0 && code != e.Code {
+			return fmt.Errorf("expect error %d, got %s", e.Code, o)
+		}
+		return nil
+	}
+	if o.err != nil {
+		return o.err
+	}
+	if e.Result != nil && o.result != *e.Result {
+		return fmt.Errorf("expect result %q, got %q", *e.Result, o.result)
+	}
+	return nil
+}
+
+// a session of the script, running one statement at a time.
+type scriptSession struct {
+	*client.Session
+	running chan outcome // of the blocked statement, nil if there is none.
+}
+
+// Run executes the steps in order, each session on a connection of its own.
+// The sessions are closed at the end, rolling back their open transactions.
+func (s *Script) Run() error {
+	sessions := make(map[string]*scriptSession)
+	defer func() {
+		for _, session := range sessions {
+			session.close()
+		}
+	}()
+
+	for _, step := range s.Steps {
+		session, ok := sessions[step.Session]
+		if !ok {
+			cs, err := client.NewSession(s.DSN)
+			if err != nil {
+				return &StepError{Path: s.Path, Step: step, Err: err}
+			}
+			cs.Log = s.Log
+			session = &scriptSession{Session: cs}
+			sessions[step.Session] = session
+		}
+		if err := s.runStep(session, step); err != nil {
+			return &StepError{Path: s.Path, Step: step, Err: err}
+		}
+	}
+	s.logger().Printf("script %s done: %d steps, %d sessions", s.Path, len(s.Steps), len(sessions))
+	return nil
+}
+
+// close the session, after its blocked statement finishes if there is one,
+// which may wait for the other sessions to close.
+func (session *scriptSession) close() {
+	if session.running == nil {
+		_ = session.Close()
+		return
+	}
+	go func() {
+		<-session.running
+		_ = session.Close()
+	}()
+}
+
+func (s *Script) runStep(session *scriptSession, step Step) error {
+	if step.Expect.Unblocked {
+		select {
+		case o := <-session.running:
+			session.running = nil
+			return step.Expect.check(o)
+		case <-time.After(s.waitTimeout()):
+			return fmt.Errorf("still blocked after %s", s.waitTimeout())
+		}
+	}
+
+	running := make(chan outcome, 1)
+	go func() {
+		running <- s.exec(session.Session, step.SQL)
+	}()
+	select {
+	case o := <-running:
+		if step.Expect.Blocked {
+			return fmt.Errorf("expect blocked, finished with %s", o)
+		}
+		return step.Expect.check(o)
+	case <-time.After(s.blockTimeout()):
+		session.running = running
+		if !step.Expect.Blocked {
+			return fmt.Errorf("blocked for %s", s.blockTimeout())
+		}
+		return nil
+	}
+}
+
+// run the statement and read its rows, the database of the run is named as
+// the one of the case in the result.
+func (s *Script) exec(session *client.Session, sql string) outcome {
+	rows, err := session.Query(sql)
+	if err != nil {
+		return outcome{err: err}
+	}
+	result, err := verify.ReadQueryResult(rows)
+	if err != nil {
+		return outcome{err: err}
+	}
+	o := outcome{result: result.ToOneString()}
+	if s.runDatabase != "" && s.runDatabase != s.database {
+		o.result = strings.Replace(o.result, s.runDatabase, s.database, -1)
+	}
+	return o
+}
+
+func (s *Script) blockTimeout() time.Duration {
+	if s.BlockTimeout > 0 {
+		return s.BlockTimeout
+	}
+	return DefaultBlockTimeout
+}
+
+func (s *Script) waitTimeout() time.Duration {
+	if s.WaitTimeout > 0 {
+		return s.WaitTimeout
+	}
+	return DefaultWaitTimeout
+}
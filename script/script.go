@@ -0,0 +1,209 @@
+package script
+
+import (
+	"concurrent-sql/util"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// default timeouts of a script.
+const (
+	DefaultBlockTimeout = time.Second
+	DefaultWaitTimeout  = time.Minute
+)
+
+// Script runs steps on several sessions in a fixed order, to reproduce lock
+// waits and isolation anomalies. Each step runs one statement on a session and
+// checks its outcome, sample:
+//
+//	s1: BEGIN;
+//	s2: BEGIN;
+//	s1: UPDATE t SET v = 1 WHERE id = 1;
+//	s2: UPDATE t SET v = 2 WHERE id = 1; -- expect blocked
+//	s1: COMMIT;
+//	s2: -- expect unblocked
+//	s2: SELECT v FROM t WHERE id = 1; -- expect result 2
+type Script struct {
+	Path  string
+	Steps []Step
+	DSN   string
+
+	// a statement running longer is blocked, the others must finish before it.
+	BlockTimeout time.Duration
+	// how long an unblocked step waits for the blocked statement to finish.
+	WaitTimeout time.Duration
+	Log         *log.Logger
+
+	// the database of the case and the one of the run, replaced back in results.
+	database, runDatabase string
+}
+
+// Step is one line of a script.
+type Step struct {
+	Line    int
+	Session string
+	SQL     string // "" for an unblocked step.
+	Expect  Expect
+}
+
+// Expect is the outcome a step requires, from its -- expect comment. The
+// statement finishes without error if nothing else is required.
+type Expect struct {
+	Blocked   bool // the statement is still running after the block timeout.
+	Unblocked bool // the blocked statement of the session finishes.
+	Error     bool // the statement fails, with Code if it is not 0.
+	Code      uint16
+	Result    *string // the rows split by \n, the columns by \t.
+}
+
+func (e Expect) String() string {
+	var words []string
+	if e.Blocked {
+		words = append(words, "blocked")
+	}
+	if e.Unblocked {
+		words = append(words, "unblocked")
+	}
+	if e.Error {
+		words = append(words, "error")
+		if e.Code != 0 {
+			words = append(words, strconv.Itoa(int(e.Code)))
+		}
+	}
+	if e.Result != nil {
+		words = append(words, "result", *e.Result)
+	}
+	if len(words) == 0 {
+		return "ok"
+	}
+	return strings.Join(words, " ")
+}
+
+// session: statement -- expect outcome, the statement and the comment are optional.
+var stepPattern = regexp.MustCompile(`^(\w+)\s*:\s*(.*?)\s*(?:--\s*expect\b\s*(.*))?$`)
+
+// parse the outcome after -- expect, sample: blocked, unblocked error 1213, result 1\t2
+func parseExpect(text string) (Expect, error) {
+	var e Expect
+	fields := strings.Fields(text)
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "ok":
+		case "blocked":
+			e.Blocked = true
+		case "unblocked":
+			e.Unblocked = true
+		case "error":
+			e.Error = true
+			if i+1 < len(fields) {
+				if code, err := strconv.ParseUint(fields[i+1], 10, 16); err == nil {
+					e.Code = uint16(code)
+					i++
+				}
+			}
+		case "result":
+			// the rest of the line, with \n and \t escaped.
+			rest := strings.TrimSpace(text[strings.Index(text, "result")+len("result"):])
+			result := strings.NewReplacer(`\n`, "\n", `\t`, "\t").Replace(rest)
+			e.Result = &result
+			i = len(fields)
+		default:
+			return e, fmt.Errorf("invalid expect: %s", fields[i])
+		}
+	}
+	if e.Blocked && (e.Unblocked || e.Error || e.Result != nil) {
+		return e, fmt.Errorf("a blocked statement has no other outcome: %s", text)
+	}
+	if e.Error && e.Result != nil {
+		return e, fmt.Errorf("a failed statement has no result: %s", text)
+	}
+	return e, nil
+}
+
+// Parse reads the steps of a script, one per line. Empty lines and lines
+// starting with -- or # are skipped.
+func Parse(text string) ([]Step, error) {
+	var steps []Step
+	blocked := make(map[string]int) // the line of the blocked statement of each session.
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "--") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		m := stepPattern.FindStringSubmatch(line)
+		if m == nil {
+			return nil, fmt.Errorf("line %d: expect session: statement", i+1)
+		}
+		step := Step{Line: i + 1, Session: m[1], SQL: strings.TrimSpace(strings.TrimSuffix(m[2], ";"))}
+		var err error
+		if step.Expect, err = parseExpect(m[3]); err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+
+		blockedAt, isBlocked := blocked[step.Session]
+		switch {
+		case step.Expect.Unblocked && step.SQL != "":
+			return nil, fmt.Errorf("line %d: an unblocked step has no statement", i+1)
+		case step.Expect.Unblocked && !isBlocked:
+			return nil, fmt.Errorf("line %d: session %s has no blocked statement", i+1, step.Session)
+		case !step.Expect.Unblocked && step.SQL == "":
+			return nil, fmt.Errorf("line %d: no statement", i+1)
+		case !step.Expect.Unblocked && isBlocked:
+			return nil, fmt.Errorf("line %d: session %s is blocked at line %d", i+1, step.Session, blockedAt)
+		}
+		if step.Expect.Blocked {
+			blocked[step.Session] = step.Line
+		} else {
+			delete(blocked, step.Session)
+		}
+		steps = append(steps, step)
+	}
+	for session, line := range blocked {
+		return nil, fmt.Errorf("line %d: the blocked statement of session %s is not unblocked", line, session)
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("no steps")
+	}
+	return steps, nil
+}
+
+// Load the steps of the script file.
+func (s *Script) Load(path string) error {
+	s.Path = path
+	lines, err := util.ReadFileLines(path)
+	if err != nil {
+		return err
+	}
+	if s.Steps, err = Parse(strings.Join(lines, "\n")); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+// UseDatabase rewrites the statements naming database from to name database to,
+// and query results to name from again, so the expects do not change.
+func (s *Script) UseDatabase(from, to string) error {
+	for i := range s.Steps {
+		if s.Steps[i].SQL == "" {
+			continue
+		}
+		q, err := util.RenameDatabase(s.Steps[i].SQL, from, to)
+		if err != nil {
+			return err
+		}
+		s.Steps[i].SQL = q
+	}
+	if s.database == "" {
+		s.database = from
+	}
+	s.runDatabase = to
+	return nil
+}
+
+func (s *Script) logger() *log.Logger {
+	return util.Logger(s.Log)
+}
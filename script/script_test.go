@@ -0,0 +1,81 @@
+package script
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestParse(t *testing.T) {
+	text := `-- a lock wait
+s1: BEGIN;
+s2: BEGIN;
+s1: UPDATE t SET v = 1 WHERE id = 1;
+s2: UPDATE t SET v = 2 WHERE id = 1; -- expect blocked
+
+s1: COMMIT;
+s2: -- expect unblocked
+s2: SELECT id, v FROM t WHERE id = 1; -- expect result 1\t2
+s1: INSERT INTO t VALUES (1, 1); -- expect error 1062
+`
+	steps, err := Parse(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := "1\t2"
+	expect := []Step{
+		{Line: 2, Session: "s1", SQL: "BEGIN"},
+		{Line: 3, Session: "s2", SQL: "BEGIN"},
+		{Line: 4, Session: "s1", SQL: "UPDATE t SET v = 1 WHERE id = 1"},
+		{Line: 5, Session: "s2", SQL: "UPDATE t SET v = 2 WHERE id = 1", Expect: Expect{Blocked: true}},
+		{Line: 7, Session: "s1", SQL: "COMMIT"},
+		{Line: 8, Session: "s2", Expect: Expect{Unblocked: true}},
+		{Line: 9, Session: "s2", SQL: "SELECT id, v FROM t WHERE id = 1", Expect: Expect{Result: &result}},
+		{Line: 10, Session: "s1", SQL: "INSERT INTO t VALUES (1, 1)", Expect: Expect{Error: true, Code: 1062}},
+	}
+	if !reflect.DeepEqual(steps, expect) {
+		t.Fatalf("bad steps: %+v", steps)
+	}
+
+	bad := []string{
+		"update t set v = 1",
+		"s1:",
+		"s1: update t set v = 1 -- expect slow",
+		"s1: -- expect unblocked",
+		"s1: update t set v = 1 -- expect blocked error",
+		"s1: update t set v = 1 -- expect blocked\ns1: commit",
+		"s1: update t set v = 1 -- expect blocked",
+		"s1: update t set v = 1 -- expect blocked\ns1: commit -- expect unblocked",
+	}
+	for _, text := range bad {
+		if _, err := Parse(text); err == nil {
+			t.Errorf("parse %q should fail", text)
+		}
+	}
+}
+
+func TestExpect_check(t *testing.T) {
+	duplicate := &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}
+	result := "1\t2"
+	checks := []struct {
+		expect Expect
+		outcome
+		ok bool
+	}{
+		{Expect{}, outcome{result: "no result"}, true},
+		{Expect{}, outcome{err: duplicate}, false},
+		{Expect{Error: true}, outcome{err: errors.New("bad")}, true},
+		{Expect{Error: true, Code: 1062}, outcome{err: duplicate}, true},
+		{Expect{Error: true, Code: 1213}, outcome{err: duplicate}, false},
+		{Expect{Error: true}, outcome{result: "no result"}, false},
+		{Expect{Result: &result}, outcome{result: "1\t2"}, true},
+		{Expect{Result: &result}, outcome{result: "1\t3"}, false},
+	}
+	for _, c := range checks {
+		if err := c.expect.check(c.outcome); (err == nil) != c.ok {
+			t.Errorf("check %s with %s should pass: %v, err=%v", c.expect, c.outcome, c.ok, err)
+		}
+	}
+}
@@ -0,0 +1,5 @@
+DROP DATABASE IF EXISTS bank;
+CREATE DATABASE bank;
+USE bank;
+CREATE TABLE accounts (id INT PRIMARY KEY, balance INT NOT NULL);
+INSERT INTO accounts VALUES (1, 1000), (2, 1000), (3, 1000), (4, 1000), (5, 1000), (6, 1000), (7, 1000), (8, 1000), (9, 1000), (10, 1000);
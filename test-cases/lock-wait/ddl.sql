@@ -0,0 +1,5 @@
+DROP DATABASE IF EXISTS lock_wait;
+CREATE DATABASE lock_wait;
+USE lock_wait;
+CREATE TABLE t (id INT PRIMARY KEY, v INT NOT NULL);
+INSERT INTO t VALUES (1, 0), (2, 0);
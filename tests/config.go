@@ -1,6 +1,8 @@
 package tests
 
 import (
+	"concurrent-sql/dml"
+	"concurrent-sql/util"
 	"errors"
 	"fmt"
 	"github.com/go-ini/ini"
@@ -9,15 +11,70 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Config struct {
+	Path             string // directory of the case.ini.
 	DSN              string
+	Database         string
+	Isolate          bool // run in a fresh, uniquely named database instead of Database.
+	DropDatabase     bool // drop the database after each run, by default if isolated.
 	DDLFile          string
+	Session          []util.Variable // system variables set on every connection of the case.
+	Matrix           []MatrixAxis    // the case runs once for each combination of their values.
 	DMLdsn           string
-	DMLFiles         []string
-	DMLRepeats       []int
+	DMLs             []DMLConfig
 	VerificationFile string
+
+	// the interleaving scripts of the [Script] section, run after the DDL.
+	Scripts      []string
+	ScriptDSN    string // the dsn of the script sessions, DMLdsn if empty.
+	BlockTimeout time.Duration
+	WaitTimeout  time.Duration
+
+	// admin check the tables created by the DDL file after the dml, and every
+	// AutoAdminCheckInterval seconds during the dml if it is positive.
+	AutoAdminCheck         bool
+	AutoAdminCheckInterval int
+}
+
+// MatrixAxis is a system variable of the [Matrix] section and its values.
+type MatrixAxis struct {
+	Name   string
+	Values []string
+}
+
+// Variants are the combinations of the values of the matrix, in the order of
+// its keys, the last one changing first. A case without a matrix has one
+// variant without variables.
+func (c *Config) Variants() [][]util.Variable {
+	variants := [][]util.Variable{nil}
+	for _, axis := range c.Matrix {
+		var next [][]util.Variable
+		for _, variant := range variants {
+			for _, value := range axis.Values {
+				v := append(append([]util.Variable(nil), variant...), util.Variable{Name: axis.Name, Value: value})
+				next = append(next, v)
+			}
+		}
+		variants = next
+	}
+	return variants
+}
+
+// DMLConfig is one file of the [DML] section.
+type DMLConfig struct {
+	File     string
+	Repeats  int
+	Workers  int             // concurrent connections, 0 for one.
+	Duration time.Duration   // run until it passes instead of Repeats times.
+	QPS      float64         // statements per second of all workers, 0 for no limit.
+	Tolerate []uint16        // error codes counted instead of failing the case.
+	Retries  int             // times a failed transaction is run again.
+	RetryOn  []uint16        // error codes of the transactions run again.
+	Transfer *dml.Transfer   // the built-in transfer workload, if File is @transfer.
+	Session  []util.Variable // over the ones of the [Session] section.
 }
 
 // find all case in dir and sub directories of dir, recursively.
@@ -59,13 +116,30 @@ func findAllConfigs(dir string) ([]string, error) {
 		[Global]
 		dsn=root:/
 		database=test
+		isolate=true
+		drop_database=true
+		[Session]
+		tidb_txn_mode=pessimistic
+		tx_isolation=READ-COMMITTED
+		[Matrix]
+		tidb_txn_mode=optimistic,pessimistic
+		tidb_enable_async_commit=0,1
 		[DDL]
 		file=ddl.sql
 		[DML]
 		file=b.txt,1000
-		file2=dml-2.sql,2000
+		file2=dml-2.sql,2000,tolerate=1062|1213
+		file3=dml-3.sql,repeats=100,workers=16
+		file4=dml-4.sql,duration=5m,qps=200,workers=4
+		file5=dml-5.sql,100,@@tidb_txn_mode=optimistic
+		[Script]
+		file=lock-wait.script
+		block_timeout=1s
+		wait_timeout=1m
 		[Verify]
-		query=query.json
+		verify=verification.json
+		auto_admin_check=true
+		auto_admin_check_interval=10
 
 */
 func (c *Config) Load(iniPath string) error {
@@ -76,12 +150,36 @@ func (c *Config) Load(iniPath string) error {
 	}
 
 	baseDir := path.Dir(iniPath)
+	c.Path = baseDir
 
 	// global section
 	c.DSN = iniFile.Section("Global").Key("dsn").String()
 	if c.DSN == "" {
 		return errors.New("invalid dsn or database name")
 	}
+	c.Database = iniFile.Section("Global").Key("database").String()
+	c.Isolate = iniFile.Section("Global").Key("isolate").MustBool(true)
+	// a fresh database per run would pile up, unless dropped.
+	c.DropDatabase = iniFile.Section("Global").Key("drop_database").MustBool(c.Isolate)
+
+	// session section
+	for _, key := range iniFile.Section("Session").Keys() {
+		c.Session = append(c.Session, util.Variable{Name: key.Name(), Value: key.String()})
+	}
+
+	// matrix section
+	for _, key := range iniFile.Section("Matrix").Keys() {
+		axis := MatrixAxis{Name: key.Name()}
+		for _, value := range strings.Split(key.String(), ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				axis.Values = append(axis.Values, value)
+			}
+		}
+		if len(axis.Values) == 0 {
+			return fmt.Errorf("invalid matrix values of %s", axis.Name)
+		}
+		c.Matrix = append(c.Matrix, axis)
+	}
 
 	// ddl section
 	if ddlFile := iniFile.Section("DDL").Key("file").String(); ddlFile == "" {
@@ -90,11 +188,13 @@ func (c *Config) Load(iniPath string) error {
 		c.DDLFile = path.Join(baseDir, ddlFile)
 	}
 
+	// script section
+	if err := c.loadScripts(iniFile.Section("Script"), baseDir); err != nil {
+		return err
+	}
+
 	// dml section
 	c.DMLdsn = iniFile.Section("DML").Key("dsn").String()
-	if c.DMLdsn == "" {
-		return errors.New("empty dml dsn")
-	}
 	keys := iniFile.Section("DML").KeyStrings()
 	for _, key := range keys {
 		if key == "dsn" {
@@ -102,20 +202,38 @@ func (c *Config) Load(iniPath string) error {
 		}
 
 		dmlConfig := iniFile.Section("DML").Key(key).String()
-		if fileName, repeats, err := c.parseDML(dmlConfig); err != nil {
+		if cfg, err := c.parseDML(dmlConfig); err != nil {
 			return err
 		} else {
-			c.DMLFiles = append(c.DMLFiles, path.Join(baseDir, fileName))
-			c.DMLRepeats = append(c.DMLRepeats, repeats)
+			if cfg.Transfer == nil {
+				cfg.File = path.Join(baseDir, cfg.File)
+			}
+			c.DMLs = append(c.DMLs, cfg)
 		}
 	}
-	if len(c.DMLFiles) == 0 {
+	if len(c.DMLs) == 0 && len(c.Scripts) == 0 {
 		return errors.New("invalid dml files")
 	}
+	if c.ScriptDSN == "" {
+		c.ScriptDSN = c.DMLdsn
+	}
+	if c.DMLdsn == "" && (len(c.DMLs) > 0 || c.ScriptDSN == "") {
+		return errors.New("empty dml dsn")
+	}
 
 	// verify section
-	if verifyFile := iniFile.Section("Verify").Key("verify").String(); verifyFile == "" {
-		return errors.New(fmt.Sprintf("invalid verify file: %s", verifyFile))
+	verifySection := iniFile.Section("Verify")
+	if verifySection.HasKey("auto_admin_check") {
+		if c.AutoAdminCheck, err = verifySection.Key("auto_admin_check").Bool(); err != nil {
+			return errors.New(fmt.Sprintf("invalid auto_admin_check: %s", err))
+		}
+	}
+	c.AutoAdminCheckInterval = verifySection.Key("auto_admin_check_interval").MustInt(0)
+	if verifyFile := verifySection.Key("verify").String(); verifyFile == "" {
+		// the verify file is optional with the auto admin check, and for scripts checking their own steps.
+		if !c.AutoAdminCheck && len(c.Scripts) == 0 {
+			return errors.New(fmt.Sprintf("invalid verify file: %s", verifyFile))
+		}
 	} else {
 		c.VerificationFile = path.Join(baseDir, verifyFile)
 	}
@@ -123,26 +241,115 @@ func (c *Config) Load(iniPath string) error {
 	return nil
 }
 
-// parse dml parameter into filename and repeat count.
-// sample:  a.sql,1000 into fileName = a.sql, repeats=1000
-func (c *Config) parseDML(line string) (fileName string, repeats int, err error) {
+// load the [Script] section, the keys besides dsn and the timeouts are script files.
+func (c *Config) loadScripts(section *ini.Section, baseDir string) (err error) {
+	c.ScriptDSN = section.Key("dsn").String()
+	for _, key := range section.KeyStrings() {
+		value := section.Key(key).String()
+		switch key {
+		case "dsn":
+		case "block_timeout":
+			if c.BlockTimeout, err = time.ParseDuration(value); err == nil && c.BlockTimeout <= 0 {
+				err = fmt.Errorf("invalid block_timeout: %s", value)
+			}
+		case "wait_timeout":
+			if c.WaitTimeout, err = time.ParseDuration(value); err == nil && c.WaitTimeout <= 0 {
+				err = fmt.Errorf("invalid wait_timeout: %s", value)
+			}
+		default:
+			if value == "" {
+				err = errors.New("invalid script file name")
+			}
+			c.Scripts = append(c.Scripts, path.Join(baseDir, value))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parse dml parameter into filename, repeat count and options.
+// sample:  a.sql,1000 into File = a.sql, Repeats=1000
+// sample:  a.sql,1000,tolerate=1062|1213 also counts duplicate key and deadlock errors instead of failing.
+// sample:  @transfer,1000,table=accounts,accounts=10,amount=100 runs the built-in transfer workload.
+// sample:  a.sql,repeats=100,workers=16 runs a.sql 100 times on each of 16 connections.
+// sample:  a.sql,duration=5m,qps=200 runs a.sql for 5 minutes, 200 statements per second.
+// sample:  a.sql,100,@@tidb_txn_mode=optimistic sets a system variable on the connections of a.sql.
+// sample:  a.sql,100,retries=3 runs a failed transaction of a.sql up to 3 more times on write conflicts,
+// deadlocks and lock wait timeouts, retry_on=9007|1213 sets the error codes to retry.
+func (c *Config) parseDML(line string) (cfg DMLConfig, err error) {
 	params := strings.Split(line, ",")
 
-	if len(params) == 1 {
-		fileName = params[0]
-		repeats = 1
-	} else if len(params) == 2 {
-		fileName = params[0]
-		repeats, err = strconv.Atoi(params[1])
-		if repeats <= 0 {
-			repeats = 1
+	cfg.File = params[0]
+	cfg.Repeats = 1
+	hasRepeats := false
+	if cfg.File == dml.TransferFile {
+		cfg.Transfer = dml.NewTransfer()
+	}
+	for i, param := range params[1:] {
+		if kv := strings.SplitN(param, "=", 2); len(kv) == 2 {
+			switch {
+			case kv[0] == "repeats":
+				hasRepeats = true
+				if cfg.Repeats, err = strconv.Atoi(kv[1]); err == nil && cfg.Repeats <= 0 {
+					err = fmt.Errorf("invalid repeats: %s", kv[1])
+				}
+			case kv[0] == "duration":
+				if cfg.Duration, err = time.ParseDuration(kv[1]); err == nil && cfg.Duration <= 0 {
+					err = fmt.Errorf("invalid duration: %s", kv[1])
+				}
+			case kv[0] == "qps":
+				if cfg.QPS, err = strconv.ParseFloat(kv[1], 64); err == nil && cfg.QPS <= 0 {
+					err = fmt.Errorf("invalid qps: %s", kv[1])
+				}
+			case kv[0] == "workers":
+				if cfg.Workers, err = strconv.Atoi(kv[1]); err == nil && cfg.Workers <= 0 {
+					err = fmt.Errorf("invalid workers: %s", kv[1])
+				}
+			case kv[0] == "tolerate":
+				cfg.Tolerate, err = util.ParseErrorCodes(kv[1], "|")
+			case kv[0] == "retries":
+				if cfg.Retries, err = strconv.Atoi(kv[1]); err == nil && cfg.Retries <= 0 {
+					err = fmt.Errorf("invalid retries: %s", kv[1])
+				}
+			case kv[0] == "retry_on":
+				cfg.RetryOn, err = util.ParseErrorCodes(kv[1], "|")
+			case strings.HasPrefix(kv[0], "@@") && len(kv[0]) > 2:
+				cfg.Session = append(cfg.Session, util.Variable{Name: kv[0][2:], Value: kv[1]})
+			case kv[0] == "table" && cfg.Transfer != nil:
+				cfg.Transfer.Table = kv[1]
+			case kv[0] == "accounts" && cfg.Transfer != nil:
+				cfg.Transfer.Accounts, err = strconv.Atoi(kv[1])
+			case kv[0] == "amount" && cfg.Transfer != nil:
+				if cfg.Transfer.MaxAmount, err = strconv.Atoi(kv[1]); err == nil && cfg.Transfer.MaxAmount <= 0 {
+					err = fmt.Errorf("invalid amount: %s", kv[1])
+				}
+			default:
+				err = errors.New(fmt.Sprintf("invalid dml option: %s", kv[0]))
+			}
+		} else if i == 0 {
+			hasRepeats = true
+			cfg.Repeats, err = strconv.Atoi(param)
+			if cfg.Repeats <= 0 {
+				cfg.Repeats = 1
+			}
+		} else {
+			err = errors.New("invalid dml parameter")
+		}
+		if err != nil {
+			return
 		}
-	} else {
-		err = errors.New("invalid dml parameter")
 	}
 
-	if err == nil && fileName == "" {
+	if cfg.File == "" {
 		err = errors.New("invalid dml file name")
+	} else if hasRepeats && cfg.Duration > 0 {
+		err = errors.New("dml repeats and duration can not be both set")
+	} else if cfg.RetryOn != nil && cfg.Retries == 0 {
+		err = errors.New("dml retry_on needs retries")
+	} else if cfg.Retries > 0 && cfg.RetryOn == nil {
+		cfg.RetryOn = util.RetryableErrors
 	}
 
 	return
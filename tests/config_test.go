@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"concurrent-sql/dml"
+	"concurrent-sql/util"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConfig_parseDML(t *testing.T) {
+	c := &Config{}
+	lines := []struct {
+		line string
+		dml  DMLConfig
+		ok   bool
+	}{
+		{"a.sql", DMLConfig{File: "a.sql", Repeats: 1}, true},
+		{"a.sql,1000", DMLConfig{File: "a.sql", Repeats: 1000}, true},
+		{"a.sql,0", DMLConfig{File: "a.sql", Repeats: 1}, true},
+		{"a.sql,10,tolerate=1062|1213", DMLConfig{File: "a.sql", Repeats: 10, Tolerate: []uint16{1062, 1213}}, true},
+		{"a.sql,repeats=100,workers=16", DMLConfig{File: "a.sql", Repeats: 100, Workers: 16}, true},
+		{"a.sql,workers=4,tolerate=9007", DMLConfig{File: "a.sql", Repeats: 1, Workers: 4, Tolerate: []uint16{9007}}, true},
+		{"a.sql,duration=5m,qps=200", DMLConfig{File: "a.sql", Repeats: 1, Duration: 5 * time.Minute, QPS: 200}, true},
+		{"a.sql,100,retries=3", DMLConfig{File: "a.sql", Repeats: 100, Retries: 3, RetryOn: []uint16{9007, 8002, 8022, 1213, 1205}}, true},
+		{"a.sql,retries=2,retry_on=9007", DMLConfig{File: "a.sql", Repeats: 1, Retries: 2, RetryOn: []uint16{9007}}, true},
+		{"a.sql,retry_on=9007", DMLConfig{}, false},
+		{"a.sql,retries=0", DMLConfig{}, false},
+		{"a.sql,100,@@tidb_txn_mode=pessimistic,@@tx_isolation=READ-COMMITTED", DMLConfig{File: "a.sql", Repeats: 100,
+			Session: []util.Variable{{Name: "tidb_txn_mode", Value: "pessimistic"}, {Name: "tx_isolation", Value: "READ-COMMITTED"}}}, true},
+		{"a.sql,100,@@=1", DMLConfig{}, false},
+		{"a.sql,100,duration=5m", DMLConfig{}, false},
+		{"a.sql,duration=5", DMLConfig{}, false},
+		{"a.sql,qps=0", DMLConfig{}, false},
+		{"a.sql,workers=0", DMLConfig{}, false},
+		{"a.sql,repeats=x", DMLConfig{}, false},
+		{"a.sql,x", DMLConfig{}, false},
+		{"a.sql,10,20", DMLConfig{}, false},
+		{"a.sql,10,tolerate=dup", DMLConfig{}, false},
+		{"a.sql,10,unknown=1", DMLConfig{}, false},
+		{",10", DMLConfig{}, false},
+		{"@transfer,100,table=bank,accounts=5", DMLConfig{File: "@transfer", Repeats: 100, Transfer: &dml.Transfer{Table: "bank", Accounts: 5, MaxAmount: 100}}, true},
+		{"a.sql,100,table=bank", DMLConfig{}, false},
+		{"@transfer,100,amount=0", DMLConfig{}, false},
+		{"@transfer,100,amount=-5", DMLConfig{}, false},
+	}
+	for _, l := range lines {
+		dml, err := c.parseDML(l.line)
+		if (err == nil) != l.ok {
+			t.Errorf("parse %s should succeed: %v, err=%v", l.line, l.ok, err)
+		} else if l.ok && !reflect.DeepEqual(dml, l.dml) {
+			t.Errorf("parse %s: %+v, expect %+v", l.line, dml, l.dml)
+		}
+	}
+}
+
+func TestConfig_Load(t *testing.T) {
+	c := &Config{}
+	if err := c.Load("../test-cases/transaction-test/case.ini"); err != nil {
+		t.Fatalf("load failed: err=%v", err)
+	}
+	if len(c.DMLs) != 5 || c.DMLs[4].File != "../test-cases/transaction-test/dml-5.sql" || !c.AutoAdminCheck || c.AutoAdminCheckInterval != 1 {
+		t.Fatalf("bad config: %+v", c)
+	}
+	// an isolated database is dropped by default.
+	if c.Database != "test2" || !c.Isolate || !c.DropDatabase {
+		t.Fatalf("bad database config: %+v", c)
+	}
+
+	testCase := &TestCase{}
+	if err := testCase.Load(c); err != nil {
+		t.Fatalf("load case failed: err=%v", err)
+	}
+	// the admin check of table t and index j after and during the dml.
+	if len(testCase.Verifications) != 3 || len(testCase.Verifications[1].Asserts) != 2 || testCase.Verifications[2].Sleep != 1 {
+		t.Fatalf("bad verifications: %+v", testCase.Verifications)
+	}
+	if testCase.Verifications[1].Asserts[1].SQL != "ADMIN CHECK INDEX `test2`.`t` `j`;" {
+		t.Fatalf("bad admin check: %s", testCase.Verifications[1].Asserts[1].SQL)
+	}
+	// the admin checks after and during the dml do not share their asserts.
+	testCase.Verifications[1].Asserts[1].SQL = "changed"
+	if testCase.Verifications[2].Asserts[1].SQL == "changed" {
+		t.Fatalf("the admin checks share their asserts")
+	}
+}
+
+func TestConfig_Variants(t *testing.T) {
+	c := &Config{}
+	if variants := c.Variants(); !reflect.DeepEqual(variants, [][]util.Variable{nil}) {
+		t.Fatalf("bad variants without matrix: %v", variants)
+	}
+	c.Matrix = []MatrixAxis{
+		{Name: "tidb_txn_mode", Values: []string{"optimistic", "pessimistic"}},
+		{Name: "tidb_enable_async_commit", Values: []string{"0", "1"}},
+	}
+	expect := [][]util.Variable{
+		{{Name: "tidb_txn_mode", Value: "optimistic"}, {Name: "tidb_enable_async_commit", Value: "0"}},
+		{{Name: "tidb_txn_mode", Value: "optimistic"}, {Name: "tidb_enable_async_commit", Value: "1"}},
+		{{Name: "tidb_txn_mode", Value: "pessimistic"}, {Name: "tidb_enable_async_commit", Value: "0"}},
+		{{Name: "tidb_txn_mode", Value: "pessimistic"}, {Name: "tidb_enable_async_commit", Value: "1"}},
+	}
+	if variants := c.Variants(); !reflect.DeepEqual(variants, expect) {
+		t.Fatalf("bad variants: %v", variants)
+	}
+}
@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"concurrent-sql/verify"
+	"fmt"
+)
+
+// ExpectConflict is an assert of a verify file recorded with different results
+// by the variants of a [Matrix] case.
+type ExpectConflict struct {
+	File     string
+	Verify   int
+	Assert   int
+	Variants []string // the names of the variants disagreeing.
+}
+
+func (c ExpectConflict) Error() string {
+	return fmt.Sprintf("%s verify[%d].asserts[%d]: different results recorded by %v", c.File, c.Verify, c.Assert, c.Variants)
+}
+
+// RecordedUpdates merges the expects recorded by the cases into the changed
+// expects of each verify file, the files are in the order of the results. The
+// variants of a [Matrix] case share the verify file, so they must record the
+// same result for each assert, the asserts they disagree on are the conflicts.
+// The failed cases are not recorded, their results may be of a broken run.
+func RecordedUpdates(results []*CaseResult) (files []string, updates map[string][]verify.ExpectUpdate, conflicts []ExpectConflict) {
+	type key struct {
+		file           string
+		verify, assert int
+	}
+	type record struct {
+		update   verify.ExpectUpdate
+		variants []string
+		conflict bool
+	}
+	records := make(map[key]*record)
+	var order []key
+	for _, r := range results {
+		if r.Err != nil {
+			continue
+		}
+		file := r.Case.VerificationFile
+		for _, u := range r.Case.Recorded() {
+			k := key{file, u.Verify, u.Assert}
+			rec, ok := records[k]
+			if !ok {
+				rec = &record{update: u}
+				records[k] = rec
+				order = append(order, k)
+			} else if rec.update.New != u.New {
+				rec.conflict = true
+			}
+			rec.variants = append(rec.variants, r.Case.Name())
+		}
+	}
+
+	updates = make(map[string][]verify.ExpectUpdate)
+	for _, k := range order {
+		rec := records[k]
+		if rec.conflict {
+			conflicts = append(conflicts, ExpectConflict{File: k.file, Verify: k.verify, Assert: k.assert, Variants: rec.variants})
+			continue
+		}
+		if _, ok := updates[k.file]; !ok {
+			files = append(files, k.file)
+			updates[k.file] = nil
+		}
+		if rec.update.Changed() {
+			updates[k.file] = append(updates[k.file], rec.update)
+		}
+	}
+	return files, updates, conflicts
+}
@@ -0,0 +1,63 @@
+package tests
+
+import (
+	"concurrent-sql/util"
+	"concurrent-sql/verify"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// a variant of a case recording the results of its two asserts.
+func recordedCase(mode string, first, second string) *CaseResult {
+	return &CaseResult{Case: &TestCase{
+		Path:             "cases/txn",
+		Variant:          []util.Variable{{Name: "tidb_txn_mode", Value: mode}},
+		VerificationFile: "cases/txn/verification.json",
+		Verifications: []verify.Verify{{
+			Asserts: []verify.Assert{{SQL: "select 1", Expect: "1"}, {SQL: "select 2"}},
+			Results: []verify.AssertResult{{Recorded: true, Actual: first}, {Recorded: true, Actual: second}},
+		}},
+		fileVerifications: 1,
+	}}
+}
+
+func TestRecordedUpdates(t *testing.T) {
+	files, updates, conflicts := RecordedUpdates([]*CaseResult{
+		recordedCase("optimistic", "1", "2"),
+		recordedCase("pessimistic", "1", "2"),
+	})
+	expect := []verify.ExpectUpdate{{Verify: 0, Assert: 1, Old: "", New: "2"}}
+	if len(conflicts) != 0 || !reflect.DeepEqual(files, []string{"cases/txn/verification.json"}) ||
+		!reflect.DeepEqual(updates["cases/txn/verification.json"], expect) {
+		t.Fatalf("bad updates: files=%v, updates=%+v, conflicts=%v", files, updates, conflicts)
+	}
+
+	// the first assert is unchanged by one variant and changed by the other.
+	_, updates, conflicts = RecordedUpdates([]*CaseResult{
+		recordedCase("optimistic", "1", "2"),
+		recordedCase("pessimistic", "3", "2"),
+	})
+	if len(conflicts) != 1 || conflicts[0].Assert != 0 || len(updates["cases/txn/verification.json"]) != 1 {
+		t.Fatalf("bad conflicts: %+v, updates=%+v", conflicts, updates)
+	}
+	// a failed case records nothing, also no conflict.
+	failed := recordedCase("pessimistic", "3", "4")
+	failed.Err = &CaseError{Phase: PhaseDML, Verify: -1, Assert: -1, Err: errors.New("broken")}
+	files, updates, conflicts = RecordedUpdates([]*CaseResult{recordedCase("optimistic", "1", "2"), failed})
+	if len(conflicts) != 0 || len(files) != 1 || !reflect.DeepEqual(updates["cases/txn/verification.json"], expect) {
+		t.Fatalf("bad updates with a failed case: files=%v, updates=%+v, conflicts=%v", files, updates, conflicts)
+	}
+	if files, _, _ = RecordedUpdates([]*CaseResult{failed}); len(files) != 0 {
+		t.Fatalf("a failed case recorded %v", files)
+	}
+
+	_, _, conflicts = RecordedUpdates([]*CaseResult{
+		recordedCase("optimistic", "1", "2"),
+		recordedCase("pessimistic", "3", "2"),
+	})
+	if msg := conflicts[0].Error(); msg != "cases/txn/verification.json verify[0].asserts[0]: different results recorded by "+
+		"[cases/txn[tidb_txn_mode=optimistic] cases/txn[tidb_txn_mode=pessimistic]]" {
+		t.Fatalf("bad conflict message: %s", msg)
+	}
+}
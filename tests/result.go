@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"concurrent-sql/script"
+	"concurrent-sql/verify"
+	"fmt"
+	"time"
+)
+
+// the phases of a case run.
+const (
+	PhaseDDL    = "DDL"
+	PhaseScript = "script"
+	PhaseDML    = "DML"
+	PhaseVerify = "verify"
+)
+
+// CaseError is the failure of a case run, with the phase it happened in.
+// Verify and Assert are the indexes of the failing verification and assert in
+// the verify file, -1 if not known.
+type CaseError struct {
+	Phase  string
+	Verify int
+	Assert int
+	Err    error
+}
+
+func newVerifyError(verifyIndex int, err error) *CaseError {
+	e := &CaseError{Phase: PhaseVerify, Verify: verifyIndex, Assert: -1, Err: err}
+	if assertErr, ok := err.(*verify.AssertError); ok {
+		e.Assert = assertErr.Index
+	}
+	return e
+}
+
+// Location of the failing assert, sample: verify[1].asserts[2], or of the
+// failing script step, sample: lock-wait.script:4, "" if not known.
+func (e *CaseError) Location() string {
+	if stepErr, ok := e.Err.(*script.StepError); ok {
+		return stepErr.Location()
+	}
+	if e.Verify < 0 {
+		return ""
+	}
+	if e.Assert < 0 {
+		return fmt.Sprintf("verify[%d]", e.Verify)
+	}
+	return fmt.Sprintf("verify[%d].asserts[%d]", e.Verify, e.Assert)
+}
+
+func (e *CaseError) Error() string {
+	if location := e.Location(); location != "" {
+		return fmt.Sprintf("%s failed at %s: %v", e.Phase, location, e.Err)
+	}
+	return fmt.Sprintf("%s failed: %v", e.Phase, e.Err)
+}
+
+// CaseResult is the outcome of one case run, Err is nil if it passed.
+type CaseResult struct {
+	Case     *TestCase
+	Err      error
+	Duration time.Duration
+}
+
+// Phase the case failed in, "" if it passed.
+func (r *CaseResult) Phase() string {
+	if e, ok := r.Err.(*CaseError); ok {
+		return e.Phase
+	} else if r.Err != nil {
+		return "unknown"
+	}
+	return ""
+}
+
+// Location of the failing assert, "" if not known.
+func (r *CaseResult) Location() string {
+	if e, ok := r.Err.(*CaseError); ok {
+		return e.Location()
+	}
+	return ""
+}
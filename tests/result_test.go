@@ -0,0 +1,34 @@
+package tests
+
+import (
+	"concurrent-sql/script"
+	"concurrent-sql/verify"
+	"errors"
+	"testing"
+)
+
+func TestCaseError(t *testing.T) {
+	cases := []struct {
+		err      *CaseError
+		location string
+		message  string
+	}{
+		{&CaseError{Phase: PhaseDDL, Verify: -1, Assert: -1, Err: errors.New("bad sql")}, "", "DDL failed: bad sql"},
+		{&CaseError{Phase: PhaseVerify, Verify: 1, Assert: -1, Err: errors.New("bad dsn")}, "verify[1]", "verify failed at verify[1]: bad dsn"},
+		{&CaseError{Phase: PhaseScript, Verify: -1, Assert: -1, Err: &script.StepError{Path: "cases/lock-wait.script", Step: script.Step{Line: 4, Session: "s2", SQL: "update t set v = 2"}, Err: errors.New("blocked for 1s")}},
+			"lock-wait.script:4", "script failed at lock-wait.script:4: s2: update t set v = 2: blocked for 1s"},
+		{newVerifyError(2, &verify.AssertError{Index: 3, Failure: "result not equal"}), "verify[2].asserts[3]", "verify failed at verify[2].asserts[3]: assert 3: verify case failed"},
+	}
+	for _, c := range cases {
+		if location := c.err.Location(); location != c.location {
+			t.Fatalf("bad location: expect=%s, actual=%s", c.location, location)
+		}
+		if message := c.err.Error(); message != c.message {
+			t.Fatalf("bad message: expect=%s, actual=%s", c.message, message)
+		}
+		result := &CaseResult{Err: c.err}
+		if result.Phase() != c.err.Phase || result.Location() != c.location {
+			t.Fatalf("bad result: phase=%s, location=%s", result.Phase(), result.Location())
+		}
+	}
+}
@@ -1,57 +1,193 @@
 package tests
 
 import (
+	"concurrent-sql/client"
 	"concurrent-sql/ddl"
 	"concurrent-sql/dml"
+	"concurrent-sql/script"
+	"concurrent-sql/util"
 	"concurrent-sql/verify"
 	"database/sql"
 	"errors"
 	"fmt"
 	"log"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
 type TestCase struct {
+	Path          string
 	DSN           string
 	DB            string
 	DDL           ddl.DDL
 	DML           []*dml.DML
+	Scripts       []*script.Script
 	Verifications []verify.Verify
+
+	DMLdsn       string
+	ScriptDSN    string
+	Isolate      bool
+	DropDatabase bool
+
+	// system variables set on every connection of the case, and of each DML over them.
+	Session    []util.Variable
+	dmlSession [][]util.Variable
+	// the combination of the [Matrix] values this case runs with, over the [Session] ones.
+	Variant []util.Variable
+	// the record mode of the verify file, one of verify.Record*.
+	Record int
+
+	// the verify file, its verifications come first in Verifications.
+	VerificationFile  string
+	fileVerifications int
+
+	// prefixed with the case path, so the logs of cases running in parallel can be told apart.
+	Log *log.Logger
+
+	// the database the last run used, "" before the first run.
+	runDB string
+}
+
+// ids of isolated databases, unique in the process and over time.
+var lastRunID = time.Now().UnixNano()
+
+// Name of the case, the path followed by the matrix variant if it has one.
+// sample: test-cases/txn[tidb_txn_mode=pessimistic,tidb_enable_async_commit=1]
+func (testCase *TestCase) Name() string {
+	if len(testCase.Variant) == 0 {
+		return testCase.Path
+	}
+	vars := make([]string, 0, len(testCase.Variant))
+	for _, v := range testCase.Variant {
+		vars = append(vars, v.Name+"="+v.Value)
+	}
+	return fmt.Sprintf("%s[%s]", testCase.Path, strings.Join(vars, ","))
 }
 
 func (testCase *TestCase) Load(cfg *Config) error {
+	testCase.Path = cfg.Path
+	testCase.Log = util.NewLogger(fmt.Sprintf("[%s] ", testCase.Name()))
 	testCase.DSN = cfg.DSN
+	testCase.DB = cfg.Database
+	testCase.DMLdsn = cfg.DMLdsn
+	testCase.ScriptDSN = cfg.ScriptDSN
+	testCase.Session = util.MergeVariables(cfg.Session, testCase.Variant)
+	testCase.Isolate = cfg.Isolate
+	testCase.DropDatabase = cfg.DropDatabase
 
 	if err := testCase.DDL.Load(cfg.DDLFile); err != nil {
 		return err
 	}
+	testCase.DDL.Log = testCase.Log
 
-	for i := range cfg.DMLFiles {
+	for _, dmlConfig := range cfg.DMLs {
 		d := &dml.DML{}
-		if err := d.Load(cfg.DMLFiles[i]); err != nil {
+		if dmlConfig.Transfer != nil {
+			d.Path = dmlConfig.File
+			d.Transfer = dmlConfig.Transfer
+		} else if err := d.Load(dmlConfig.File); err != nil {
 			return err
 		}
-		d.Repeats = cfg.DMLRepeats[i]
-		d.DSN = cfg.DMLdsn
+		d.Repeats = dmlConfig.Repeats
+		d.Workers = dmlConfig.Workers
+		d.Duration = dmlConfig.Duration
+		d.QPS = dmlConfig.QPS
+		d.Tolerate = dmlConfig.Tolerate
+		d.MaxRetries = dmlConfig.Retries
+		d.RetryOn = dmlConfig.RetryOn
+		d.Log = testCase.Log
 
 		testCase.DML = append(testCase.DML, d)
+		testCase.dmlSession = append(testCase.dmlSession, dmlConfig.Session)
 	}
 
-	if v, err := verify.LoadVerificationFromFile(cfg.VerificationFile); err != nil {
-		return err
-	} else {
-		for i := range v {
-			v[i].DSN = cfg.DMLdsn
+	for _, file := range cfg.Scripts {
+		s := &script.Script{BlockTimeout: cfg.BlockTimeout, WaitTimeout: cfg.WaitTimeout, Log: testCase.Log}
+		if err := s.Load(file); err != nil {
+			return err
 		}
-		testCase.Verifications = v
+		testCase.Scripts = append(testCase.Scripts, s)
+	}
+
+	if cfg.VerificationFile != "" {
+		if v, err := verify.LoadVerificationFromFile(cfg.VerificationFile); err != nil {
+			return err
+		} else {
+			testCase.Verifications = v
+			testCase.VerificationFile = cfg.VerificationFile
+			testCase.fileVerifications = len(v)
+		}
+		for i := range testCase.Verifications {
+			testCase.Verifications[i].Log = testCase.Log
+			testCase.Verifications[i].Record = testCase.Record
+			if err := testCase.Verifications[i].LoadExpectFiles(cfg.Path); err != nil {
+				return fmt.Errorf("%s: verify[%d]: %v", cfg.VerificationFile, i, err)
+			}
+		}
+	}
+
+	if cfg.AutoAdminCheck {
+		tables, err := util.GetCreatedTables(cfg.DDLFile)
+		if err != nil {
+			return err
+		}
+		// each verification gets its own copy, UseDatabase rewrites the asserts in place.
+		asserts := verify.NewAdminCheckAsserts(tables)
+		testCase.Verifications = append(testCase.Verifications, verify.Verify{RunAt: verify.RUN_ONETIME, Asserts: append([]verify.Assert(nil), asserts...)})
+		if cfg.AutoAdminCheckInterval > 0 {
+			testCase.Verifications = append(testCase.Verifications, verify.Verify{RunAt: "dml_start", Sleep: cfg.AutoAdminCheckInterval, Asserts: append([]verify.Assert(nil), asserts...)})
+		}
+	}
+	for i := range testCase.Verifications {
+		testCase.Verifications[i].Log = testCase.Log
 	}
 
 	return nil
 }
 
+// Recorded are the expects of the verify file recorded in the last run.
+func (testCase *TestCase) Recorded() []verify.ExpectUpdate {
+	var recorded []verify.ExpectUpdate
+	for i := 0; i < testCase.fileVerifications; i++ {
+		recorded = append(recorded, testCase.Verifications[i].Recorded(i)...)
+	}
+	return recorded
+}
+
+// Updates are the expects of the verify file recorded in the last run which differ from the current ones.
+func (testCase *TestCase) Updates() []verify.ExpectUpdate {
+	var updates []verify.ExpectUpdate
+	for i := 0; i < testCase.fileVerifications; i++ {
+		updates = append(updates, testCase.Verifications[i].Updates(i)...)
+	}
+	return updates
+}
+
+// SetSeed sets the seed of the random values of the DML templates and workloads.
+func (testCase *TestCase) SetSeed(seed int64) {
+	for _, d := range testCase.DML {
+		d.Seed = seed
+	}
+}
+
+// Run runs the case once, an error of the case itself is a *CaseError.
 func (testCase *TestCase) Run() error {
+	defer testCase.dropDatabase()
+	if err := testCase.prepareDatabase(); err != nil {
+		return &CaseError{Phase: PhaseDDL, Verify: -1, Assert: -1, Err: err}
+	}
+
 	if err := testCase.runDDL(); err != nil {
-		return err
+		return &CaseError{Phase: PhaseDDL, Verify: -1, Assert: -1, Err: err}
+	}
+
+	for _, s := range testCase.Scripts {
+		if err := s.Run(); err != nil {
+			return &CaseError{Phase: PhaseScript, Verify: -1, Assert: -1, Err: err}
+		}
 	}
 
 	if err := testCase.runDMLAndVerify(); err != nil {
@@ -65,22 +201,126 @@ func (testCase *TestCase) Run() error {
 	return nil
 }
 
+// drop the database of the run with drop_database, whether the case passed or failed.
+func (testCase *TestCase) dropDatabase() {
+	if !testCase.DropDatabase || testCase.runDB == "" {
+		return
+	}
+	if err := testCase.execGlobal(fmt.Sprintf("DROP DATABASE IF EXISTS `%s`", testCase.runDB)); err != nil {
+		testCase.Log.Println("drop database error: ", testCase.runDB, ", ", err)
+	}
+}
+
+// prepareDatabase creates the database of this run and points the DDL, DML and
+// verify connections to it. An isolated case runs in a fresh, uniquely named
+// database, the statements naming the database of the case files are rewritten
+// to it.
+func (testCase *TestCase) prepareDatabase() error {
+	if testCase.DB == "" {
+		return testCase.prepareDSNs()
+	}
+
+	from := testCase.runDB
+	if from == "" {
+		from = testCase.DB
+	}
+	testCase.runDB = testCase.DB
+	if testCase.Isolate {
+		testCase.runDB = fmt.Sprintf("%s_%s", testCase.DB, strconv.FormatInt(atomic.AddInt64(&lastRunID, 1), 36))
+	}
+	testCase.Log.Println("case database: ", testCase.runDB)
+	if err := testCase.execGlobal(fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s`", testCase.runDB)); err != nil {
+		return err
+	}
+
+	if err := testCase.DDL.UseDatabase(from, testCase.runDB); err != nil {
+		return err
+	}
+	for _, d := range testCase.DML {
+		if err := d.UseDatabase(from, testCase.runDB); err != nil {
+			return err
+		}
+	}
+	for _, s := range testCase.Scripts {
+		if err := s.UseDatabase(from, testCase.runDB); err != nil {
+			return err
+		}
+	}
+	for i := range testCase.Verifications {
+		if err := testCase.Verifications[i].UseDatabase(from, testCase.runDB); err != nil {
+			return err
+		}
+	}
+	return testCase.prepareDSNs()
+}
+
+// dsn returns the dsn connecting to the database of the run if the case has
+// one, and setting the session variables.
+func (testCase *TestCase) dsn(dsn string, vars []util.Variable) (string, error) {
+	if testCase.runDB != "" {
+		var err error
+		if dsn, err = util.DSNWithDatabase(dsn, testCase.runDB); err != nil {
+			return "", err
+		}
+	}
+	return util.DSNWithVariables(dsn, vars)
+}
+
+// set the dsns of the DML, script and verify connections.
+func (testCase *TestCase) prepareDSNs() (err error) {
+	for i, d := range testCase.DML {
+		if d.DSN, err = testCase.dsn(testCase.DMLdsn, util.MergeVariables(testCase.Session, testCase.dmlSession[i])); err != nil {
+			return err
+		}
+	}
+	for _, s := range testCase.Scripts {
+		if s.DSN, err = testCase.dsn(testCase.ScriptDSN, testCase.Session); err != nil {
+			return err
+		}
+	}
+	verifyDSN, err := testCase.dsn(testCase.DMLdsn, testCase.Session)
+	if err != nil {
+		return err
+	}
+	for i := range testCase.Verifications {
+		testCase.Verifications[i].DSN = verifyDSN
+	}
+	return nil
+}
+
+// execute one statement on the dsn of the [Global] section.
+func (testCase *TestCase) execGlobal(query string) error {
+	db, err := sql.Open("mysql", testCase.DSN)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	_, err = db.Exec(query)
+	return err
+}
+
 func (testCase *TestCase) runDDL() error {
-	if ddlClient, err := sql.Open("mysql", testCase.DSN); err != nil {
+	dsn, err := testCase.dsn(testCase.DSN, testCase.Session)
+	if err != nil {
 		return err
-	} else if err := ddlClient.Ping(); err != nil {
-		log.Println("ping database error: ", testCase.DSN, ", ", err)
+	}
+
+	session, err := client.NewSession(dsn)
+	if err != nil {
+		testCase.Log.Println("connect database error: ", dsn, ", ", err)
 		return err
-	} else {
-		testCase.DDL.DB = ddlClient
 	}
+	session.Log = testCase.Log
+	testCase.DDL.Session = session
 
 	defer func() {
-		if err := testCase.DDL.DB.Close(); err != nil {
+		if err := testCase.DDL.Session.Close(); err != nil {
 			// print error
-			log.Println("close database error ", err)
+			testCase.Log.Println("close database error ", err)
 		} else {
-			testCase.DDL.DB = nil
+			testCase.DDL.Session = nil
 		}
 	}()
 
@@ -92,34 +332,42 @@ func (testCase *TestCase) runDDL() error {
 }
 
 func (testCase *TestCase) runDMLAndVerify() (err error) {
-	var chans []chan string
+	var cases []reflect.SelectCase
+	var verifyIndexes []int // of the verification sending on each case after the dml ones.
 	shutdown := make(chan struct{})
 	errorOccurs := false
 	dmlCount := 0
 
+	for i := range testCase.Verifications {
+		if err := testCase.Verifications[i].Prepare(); err != nil {
+			return &CaseError{Phase: PhaseVerify, Verify: i, Assert: -1, Err: err}
+		}
+	}
+
 	for i := 0; i < len(testCase.DML); i++ {
 		ch := make(chan string)
 		go testCase.DML[i].RunAsync(ch, shutdown)
-		chans = append(chans, ch)
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch)})
 		dmlCount++
 	}
 
 	// run verify.
 	for i := 0; i < len(testCase.Verifications); i++ {
 		if testCase.Verifications[i].RunAt != "dml_end" {
-			ch := make(chan string)
+			ch := make(chan error)
 			go testCase.Verifications[i].RunAsync(ch, shutdown)
-			chans = append(chans, ch)
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch)})
+			verifyIndexes = append(verifyIndexes, i)
 		}
 	}
 
-	cases := make([]reflect.SelectCase, len(chans))
-	for i, ch := range chans {
-		cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch)}
-	}
-
 	remaining := len(cases)
 	closeAlready := false
+	if dmlCount == 0 {
+		// a case of scripts only, there is no dml to run verifications during.
+		close(shutdown)
+		closeAlready = true
+	}
 
 	for remaining > 0 {
 		chosen, value, ok := reflect.Select(cases)
@@ -142,13 +390,22 @@ func (testCase *TestCase) runDMLAndVerify() (err error) {
 
 			continue
 		} else {
-			if v := value.String(); v != "" {
-				log.Println("error occurs. verify id: ", chosen, ", ", v)
+			var caseErr *CaseError
+			if chosen < dmlCount {
+				if v := value.String(); v != "" {
+					caseErr = &CaseError{Phase: PhaseDML, Verify: -1, Assert: -1, Err: errors.New(v)}
+				}
+			} else if v, _ := value.Interface().(error); v != nil {
+				caseErr = newVerifyError(verifyIndexes[chosen-dmlCount], v)
+			}
+
+			if caseErr != nil {
+				testCase.Log.Println("error occurs. verify id: ", chosen, ", ", caseErr.Err)
 
 				// notify all go routines to quit.
 				if !errorOccurs {
 					errorOccurs = true
-					err = errors.New(v)
+					err = caseErr
 					if !closeAlready {
 						close(shutdown)
 						closeAlready = true
@@ -166,24 +423,21 @@ func (testCase *TestCase) runDMLAndVerify() (err error) {
 }
 
 func (testCase *TestCase) runAfterDML() (err error) {
-	var chans []chan string
+	var cases []reflect.SelectCase
+	var verifyIndexes []int
 	shutdown := make(chan struct{})
 	errorOccurs := false
 
 	// run verify.
 	for i := 0; i < len(testCase.Verifications); i++ {
 		if testCase.Verifications[i].RunAt == "dml_end" {
-			ch := make(chan string)
+			ch := make(chan error)
 			go testCase.Verifications[i].RunAsync(ch, shutdown)
-			chans = append(chans, ch)
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch)})
+			verifyIndexes = append(verifyIndexes, i)
 		}
 	}
 
-	cases := make([]reflect.SelectCase, len(chans))
-	for i, ch := range chans {
-		cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch)}
-	}
-
 	remaining := len(cases)
 	for remaining > 0 {
 		chosen, value, ok := reflect.Select(cases)
@@ -192,13 +446,13 @@ func (testCase *TestCase) runAfterDML() (err error) {
 			remaining -= 1
 			continue
 		} else {
-			if v := value.String(); v != "" {
+			if v, _ := value.Interface().(error); v != nil {
 				errStr := fmt.Sprintf("error occurs. verify id: %d. err=%s", chosen, v)
-				log.Println(errStr)
-				err = errors.New(errStr)
+				testCase.Log.Println(errStr)
 				// notify all go routines to quit.
 				if !errorOccurs {
 					errorOccurs = true
+					err = newVerifyError(verifyIndexes[chosen], v)
 					close(shutdown)
 				}
 			}
@@ -1,10 +1,14 @@
 package tests
 
 import (
+	"fmt"
 	"log"
+	"sync"
+	"time"
 )
 
-func LoadCases(dir string) ([]*TestCase, error) {
+// LoadCases loads the cases in dir, record is the record mode of their verify files.
+func LoadCases(dir string, record int) ([]*TestCase, error) {
 	configFiles, err := findAllConfigs(dir)
 	if err != nil {
 		return nil, err
@@ -20,13 +24,80 @@ func LoadCases(dir string) ([]*TestCase, error) {
 			return nil, err
 		}
 
-		c := &TestCase{}
-		if err := c.Load(cfg); err != nil {
-			return nil, err
-		} else {
+		// a case with a [Matrix] runs once for each combination, in databases of their own
+		// so they do not drop each other's tables.
+		if len(cfg.Matrix) > 0 && (cfg.Database == "" || !cfg.Isolate) {
+			return nil, fmt.Errorf("%s: a case with [Matrix] must set database= in [Global] and not isolate=false", o)
+		}
+		for _, variant := range cfg.Variants() {
+			c := &TestCase{Variant: variant, Record: record}
+			if err := c.Load(cfg); err != nil {
+				return nil, err
+			}
 			testCases = append(testCases, c)
 		}
 	}
 
 	return testCases, nil
 }
+
+// RunCases runs the cases, at most parallel of them at the same time, and
+// returns their results in the order of the cases. With failFast, no case is
+// started after one fails, the results of the cases not run are absent.
+func RunCases(testCases []*TestCase, parallel int, failFast bool) []*CaseResult {
+	if parallel < 1 {
+		parallel = 1
+	}
+	if parallel > 1 {
+		for _, c := range testCases {
+			if c.DB == "" || !c.Isolate {
+				log.Printf("case %s does not run in a database of its own, set database= in [Global] to isolate it from the cases running in parallel", c.Name())
+			}
+		}
+	}
+
+	results := make([]*CaseResult, len(testCases))
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed bool
+	)
+	slots := make(chan struct{}, parallel)
+	for i, c := range testCases {
+		slots <- struct{}{}
+		mu.Lock()
+		stop := failed
+		mu.Unlock()
+		if stop {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, c *TestCase) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			start := time.Now()
+			err := c.Run()
+			results[i] = &CaseResult{Case: c, Err: err, Duration: time.Since(start)}
+			if err != nil {
+				c.Log.Println("case failed: ", err)
+				if failFast {
+					mu.Lock()
+					failed = true
+					mu.Unlock()
+				}
+			}
+		}(i, c)
+	}
+	wg.Wait()
+
+	var ran []*CaseResult
+	for _, r := range results {
+		if r != nil {
+			ran = append(ran, r)
+		}
+	}
+	return ran
+}
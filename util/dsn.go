@@ -0,0 +1,75 @@
+package util
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// DSNWithDatabase returns the dsn connecting to the database instead of the one it names.
+func DSNWithDatabase(dsn, database string) (string, error) {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return "", err
+	}
+	cfg.DBName = database
+	return cfg.FormatDSN(), nil
+}
+
+// Variable is a system variable set on the connections, like tidb_txn_mode=pessimistic.
+type Variable struct {
+	Name  string
+	Value string
+}
+
+// MergeVariables returns the variables of base with the ones of override, which
+// replace the ones of the same name.
+func MergeVariables(base, override []Variable) []Variable {
+	merged := append([]Variable(nil), base...)
+	for _, o := range override {
+		replaced := false
+		for i := range merged {
+			if strings.EqualFold(merged[i].Name, o.Name) {
+				merged[i].Value = o.Value
+				replaced = true
+			}
+		}
+		if !replaced {
+			merged = append(merged, o)
+		}
+	}
+	return merged
+}
+
+// the value as it is written in a SET statement, words are quoted.
+// sample: pessimistic into 'pessimistic', 1 and 'ANSI' as they are.
+func sqlValue(value string) string {
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return value
+	}
+	if len(value) >= 2 && (value[0] == '\'' || value[0] == '"') && value[len(value)-1] == value[0] {
+		return value
+	}
+	return "'" + strings.Replace(value, "'", "''", -1) + "'"
+}
+
+// DSNWithVariables returns the dsn setting the variables on every new
+// connection, which the driver does by SET statements after it connects, so
+// they hold after a reconnect too.
+func DSNWithVariables(dsn string, vars []Variable) (string, error) {
+	if len(vars) == 0 {
+		return dsn, nil
+	}
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return "", err
+	}
+	if cfg.Params == nil {
+		cfg.Params = make(map[string]string)
+	}
+	for _, v := range vars {
+		cfg.Params[v.Name] = sqlValue(v.Value)
+	}
+	return cfg.FormatDSN(), nil
+}
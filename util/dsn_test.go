@@ -0,0 +1,32 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestDSNWithVariables(t *testing.T) {
+	vars := MergeVariables(
+		[]Variable{{"tidb_txn_mode", "optimistic"}, {"sql_mode", "STRICT_TRANS_TABLES,NO_ZERO_DATE"}},
+		[]Variable{{"tidb_txn_mode", "pessimistic"}, {"tidb_enable_index_merge", "1"}, {"tx_isolation", "'READ-COMMITTED'"}},
+	)
+	dsn, err := DSNWithVariables("root@tcp(127.0.0.1:4000)/test?allowNativePasswords=true", vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string]string{
+		"tidb_txn_mode":           "'pessimistic'",
+		"sql_mode":                "'STRICT_TRANS_TABLES,NO_ZERO_DATE'",
+		"tidb_enable_index_merge": "1",
+		"tx_isolation":            "'READ-COMMITTED'",
+	}
+	if !reflect.DeepEqual(cfg.Params, expect) || cfg.DBName != "test" || !cfg.AllowNativePasswords {
+		t.Fatalf("bad dsn %s: %+v", dsn, cfg)
+	}
+}
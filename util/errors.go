@@ -0,0 +1,54 @@
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// ErrorCode returns the MySQL error code of err, ok is false if err is not an
+// error returned by the server.
+func ErrorCode(err error) (code uint16, ok bool) {
+	if me, isMySQL := err.(*mysql.MySQLError); isMySQL {
+		return me.Number, true
+	}
+	return 0, false
+}
+
+// ParseErrorCodes parses a list of error codes split by sep.
+// sample: "1062|1213" into [1062, 1213]
+func ParseErrorCodes(list string, sep string) ([]uint16, error) {
+	var codes []uint16
+	for _, s := range strings.Split(list, sep) {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		code, err := strconv.ParseUint(s, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid error code: %s", s)
+		}
+		codes = append(codes, uint16(code))
+	}
+	return codes, nil
+}
+
+// RetryableErrors are the error codes of a transaction which may succeed when
+// it is run again: write conflicts (9007, 8002 and 8022), deadlocks (1213) and
+// lock wait timeouts (1205).
+var RetryableErrors = []uint16{9007, 8002, 8022, 1213, 1205}
+
+// HasErrorCode returns whether err is a server error with one of the codes.
+func HasErrorCode(err error, codes []uint16) bool {
+	code, ok := ErrorCode(err)
+	if !ok {
+		return false
+	}
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
@@ -2,11 +2,13 @@ package util
 
 import (
 	"bufio"
+	"fmt"
 	"io/ioutil"
 	"os"
 
 	"github.com/pingcap/log"
 	"github.com/pingcap/parser"
+	"github.com/pingcap/parser/ast"
 
 	_ "github.com/pingcap/tidb/types/parser_driver"
 )
@@ -34,14 +36,34 @@ func ReadFileLines(path string) ([]string, error) {
 }
 
 func GetSQLStatements(path string) ([]string, error) {
+	stmts, _, err := GetSQLTransactions(path)
+	return stmts, err
+}
+
+// Transaction is a group of statements of a file, from a BEGIN or START
+// TRANSACTION to its COMMIT or ROLLBACK, both included.
+type Transaction struct {
+	Begin, End int  // indexes of the first and the last statement.
+	Rollback   bool // whether it ends with ROLLBACK.
+}
+
+// GetSQLTransactions returns the statements of the file, and the transactions
+// they form in order.
+func GetSQLTransactions(path string) ([]string, []Transaction, error) {
 	sqlBytes, err := ioutil.ReadFile(path)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	return parseSQL(string(sqlBytes))
+}
+
+func parseSQL(sql string) ([]string, []Transaction, error) {
+	// placeholders of DML templates are put back after parsing.
+	text, placeholders := markTemplates(sql)
 	p := parser.New()
-	stmts, warns, err := p.Parse(string(sqlBytes), "", "")
+	stmts, warns, err := p.Parse(text, "", "")
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	for _, w := range warns {
 		log.Info("warn: " + w.Error())
@@ -49,7 +71,37 @@ func GetSQLStatements(path string) ([]string, error) {
 
 	lines := make([]string, 0, 10)
 	for _, stmt := range stmts {
-		lines = append(lines, stmt.Text())
+		lines = append(lines, unmarkTemplates(stmt.Text(), placeholders))
 	}
-	return lines, nil
+	txns, err := transactions(stmts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return lines, txns, nil
+}
+
+// group the statements into transactions, a COMMIT or ROLLBACK out of a
+// transaction is a statement of its own.
+func transactions(stmts []ast.StmtNode) ([]Transaction, error) {
+	var txns []Transaction
+	begin := -1
+	for i, stmt := range stmts {
+		switch stmt.(type) {
+		case *ast.BeginStmt:
+			if begin >= 0 {
+				return nil, fmt.Errorf("statement %d begins a transaction in the one begun by statement %d", i+1, begin+1)
+			}
+			begin = i
+		case *ast.CommitStmt, *ast.RollbackStmt:
+			if begin >= 0 {
+				_, rollback := stmt.(*ast.RollbackStmt)
+				txns = append(txns, Transaction{Begin: begin, End: i, Rollback: rollback})
+				begin = -1
+			}
+		}
+	}
+	if begin >= 0 {
+		return nil, fmt.Errorf("the transaction begun by statement %d is not committed or rolled back", begin+1)
+	}
+	return txns, nil
 }
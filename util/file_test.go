@@ -0,0 +1,31 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSQL(t *testing.T) {
+	files := []struct {
+		sql   string
+		stmts int
+		txns  []Transaction
+		ok    bool
+	}{
+		{"insert into t values (1); update t set a = 2;", 2, nil, true},
+		{"begin; update t set a = 1; commit; insert into t values (1);", 4, []Transaction{{Begin: 0, End: 2}}, true},
+		{"start transaction; update t set a = {{randInt 1 9}}; rollback; begin pessimistic; delete from t; commit;", 6,
+			[]Transaction{{Begin: 0, End: 2, Rollback: true}, {Begin: 3, End: 5}}, true},
+		{"commit; update t set a = 1;", 2, nil, true},
+		{"begin; update t set a = 1;", 0, nil, false},
+		{"begin; begin; commit;", 0, nil, false},
+	}
+	for _, f := range files {
+		stmts, txns, err := parseSQL(f.sql)
+		if (err == nil) != f.ok {
+			t.Errorf("parse %s should succeed: %v, err=%v", f.sql, f.ok, err)
+		} else if f.ok && (len(stmts) != f.stmts || !reflect.DeepEqual(txns, f.txns)) {
+			t.Errorf("parse %s: %d statements, transactions %+v", f.sql, len(stmts), txns)
+		}
+	}
+}
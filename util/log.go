@@ -0,0 +1,21 @@
+package util
+
+import (
+	"log"
+	"os"
+)
+
+var defaultLogger = log.New(os.Stderr, "", log.LstdFlags)
+
+// NewLogger returns a logger printing like the standard one, with every line prefixed by prefix.
+func NewLogger(prefix string) *log.Logger {
+	return log.New(os.Stderr, prefix, log.LstdFlags)
+}
+
+// Logger returns l, or a logger printing like the standard one if l is nil.
+func Logger(l *log.Logger) *log.Logger {
+	if l != nil {
+		return l
+	}
+	return defaultLogger
+}
@@ -0,0 +1,251 @@
+package util
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/pingcap/parser"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/format"
+	"github.com/pingcap/parser/model"
+)
+
+var orderByPattern = regexp.MustCompile(`(?i)\border\s+by\b`)
+
+// HasOrderBy returns whether the rows of the query come in a defined order,
+// that is the outermost select or union has an ORDER BY clause.
+func HasOrderBy(query string) bool {
+	stmt, err := parser.New().ParseOneStmt(query, "", "")
+	if err != nil {
+		// not a statement of the parser, guess from the text.
+		return orderByPattern.MatchString(query)
+	}
+
+	switch s := stmt.(type) {
+	case *ast.SelectStmt:
+		return s.OrderBy != nil
+	case *ast.UnionStmt:
+		return s.OrderBy != nil
+	default:
+		return false
+	}
+}
+
+// tableHinter sets an index hint on every reference to one table.
+type tableHinter struct {
+	schema, table string
+	hint          *ast.IndexHint
+	found         bool
+}
+
+func (h *tableHinter) Enter(n ast.Node) (ast.Node, bool) {
+	if t, ok := n.(*ast.TableName); ok && t.Name.L == strings.ToLower(h.table) &&
+		(h.schema == "" || t.Schema.L == strings.ToLower(h.schema)) {
+		t.IndexHints = []*ast.IndexHint{h.hint}
+		h.found = true
+	}
+	return n, false
+}
+
+func (h *tableHinter) Leave(n ast.Node) (ast.Node, bool) {
+	return n, true
+}
+
+// WithIndexHint rewrites the query with USE INDEX (or IGNORE INDEX if ignore is
+// true) of the indexes on every reference to the table, sample:
+// "select * from t where a = 1" into "SELECT * FROM `t` USE INDEX (`idx_a`) WHERE `a`=1"
+func WithIndexHint(query, table string, ignore bool, indexes ...string) (string, error) {
+	stmt, err := parser.New().ParseOneStmt(query, "", "")
+	if err != nil {
+		return "", err
+	}
+
+	hint := &ast.IndexHint{HintType: ast.HintUse, HintScope: ast.HintForScan}
+	if ignore {
+		hint.HintType = ast.HintIgnore
+	}
+	for _, index := range indexes {
+		hint.IndexNames = append(hint.IndexNames, model.NewCIStr(index))
+	}
+	h := &tableHinter{table: table, hint: hint}
+	if i := strings.Index(table, "."); i >= 0 {
+		h.schema, h.table = table[:i], table[i+1:]
+	}
+	stmt.Accept(h)
+	if !h.found {
+		return "", fmt.Errorf("table %s not found in %s", table, query)
+	}
+
+	var sb strings.Builder
+	if err := stmt.Restore(format.NewRestoreCtx(format.DefaultRestoreFlags, &sb)); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// Table is a table created by a DDL file, with the names of its secondary indexes.
+type Table struct {
+	Schema  string
+	Name    string
+	Indexes []string
+}
+
+// QualifiedName is the table name with its schema if known, quoted. sample: `test`.`t`
+func (t *Table) QualifiedName() string {
+	if t.Schema == "" {
+		return "`" + t.Name + "`"
+	}
+	return "`" + t.Schema + "`.`" + t.Name + "`"
+}
+
+// GetCreatedTables parses a DDL file and returns the tables it creates, in order.
+// The schema of a table without one is the database of the last USE statement.
+func GetCreatedTables(path string) ([]*Table, error) {
+	sqlBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	stmts, _, err := parser.New().Parse(string(sqlBytes), "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	var tables []*Table
+	currentDB := ""
+	find := func(tn *ast.TableName) (*Table, int) {
+		schema := tn.Schema.O
+		if schema == "" {
+			schema = currentDB
+		}
+		for i, t := range tables {
+			if strings.EqualFold(t.Schema, schema) && strings.EqualFold(t.Name, tn.Name.O) {
+				return t, i
+			}
+		}
+		return nil, -1
+	}
+
+	for _, stmt := range stmts {
+		switch s := stmt.(type) {
+		case *ast.UseStmt:
+			currentDB = s.DBName
+		case *ast.DropDatabaseStmt:
+			var kept []*Table
+			for _, t := range tables {
+				if !strings.EqualFold(t.Schema, s.Name) {
+					kept = append(kept, t)
+				}
+			}
+			tables = kept
+		case *ast.CreateTableStmt:
+			if t, _ := find(s.Table); t != nil {
+				continue
+			}
+			t := &Table{Schema: s.Table.Schema.O, Name: s.Table.Name.O}
+			if t.Schema == "" {
+				t.Schema = currentDB
+			}
+			// an unnamed index is named after its first column.
+			for _, col := range s.Cols {
+				for _, o := range col.Options {
+					if o.Tp == ast.ColumnOptionUniqKey {
+						t.Indexes = append(t.Indexes, col.Name.Name.O)
+					}
+				}
+			}
+			for _, c := range s.Constraints {
+				switch c.Tp {
+				case ast.ConstraintKey, ast.ConstraintIndex, ast.ConstraintUniq, ast.ConstraintUniqKey, ast.ConstraintUniqIndex:
+					if c.Name != "" {
+						t.Indexes = append(t.Indexes, c.Name)
+					} else if len(c.Keys) > 0 {
+						t.Indexes = append(t.Indexes, c.Keys[0].Column.Name.O)
+					}
+				}
+			}
+			tables = append(tables, t)
+		case *ast.CreateIndexStmt:
+			if t, _ := find(s.Table); t != nil {
+				t.Indexes = append(t.Indexes, s.IndexName)
+			}
+		case *ast.DropTableStmt:
+			for _, tn := range s.Tables {
+				if _, i := find(tn); i >= 0 {
+					tables = append(tables[:i], tables[i+1:]...)
+				}
+			}
+		}
+	}
+	return tables, nil
+}
+
+// databaseRenamer renames a database in USE, CREATE/DROP DATABASE and table names.
+type databaseRenamer struct {
+	from, to string
+	renamed  bool
+}
+
+func (r *databaseRenamer) rename(name *string) {
+	if strings.EqualFold(*name, r.from) {
+		*name = r.to
+		r.renamed = true
+	}
+}
+
+func (r *databaseRenamer) Enter(n ast.Node) (ast.Node, bool) {
+	switch s := n.(type) {
+	case *ast.UseStmt:
+		r.rename(&s.DBName)
+	case *ast.CreateDatabaseStmt:
+		r.rename(&s.Name)
+	case *ast.DropDatabaseStmt:
+		r.rename(&s.Name)
+	case *ast.TableName:
+		if strings.EqualFold(s.Schema.O, r.from) {
+			s.Schema = model.NewCIStr(r.to)
+			r.renamed = true
+		}
+	}
+	return n, false
+}
+
+func (r *databaseRenamer) Leave(n ast.Node) (ast.Node, bool) {
+	return n, true
+}
+
+// RenameDatabase rewrites the statement to use database to instead of from.
+// The statement is returned as is if it does not name from, or can not be parsed.
+// The placeholders of a templated statement are kept.
+// sample: "CREATE DATABASE test2" into "CREATE DATABASE `test2_k3x9`"
+func RenameDatabase(query, from, to string) (string, error) {
+	marked, placeholders := markTemplates(query)
+	stmt, err := parser.New().ParseOneStmt(marked, "", "")
+	if err != nil {
+		return query, nil
+	}
+	r := &databaseRenamer{from: from, to: to}
+	stmt.Accept(r)
+	if !r.renamed {
+		return query, nil
+	}
+
+	var sb strings.Builder
+	if err := stmt.Restore(format.NewRestoreCtx(format.DefaultRestoreFlags, &sb)); err != nil {
+		return "", err
+	}
+	return unmarkTemplates(sb.String(), placeholders), nil
+}
+
+// RenameDatabaseInAll applies RenameDatabase to every query in place.
+func RenameDatabaseInAll(queries []string, from, to string) error {
+	for i := range queries {
+		q, err := RenameDatabase(queries[i], from, to)
+		if err != nil {
+			return err
+		}
+		queries[i] = q
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHasOrderBy(t *testing.T) {
+	queries := map[string]bool{
+		"select * from t":                                   false,
+		"select * from t order by id":                       true,
+		"select * from (select * from t order by id) s":     false,
+		"select a from t union select b from s order by a":  true,
+		"explain select * from t order by id":               false,
+		"select * from t where name = 'order by' limit 1 ;": false,
+		"select a, count(*) from t group by a order by a":   true,
+	}
+	for query, ordered := range queries {
+		if HasOrderBy(query) != ordered {
+			t.Errorf("order by of %s should be %v", query, ordered)
+		}
+	}
+}
+
+func TestWithIndexHint(t *testing.T) {
+	hints := []struct {
+		query   string
+		table   string
+		ignore  bool
+		indexes []string
+		expect  string
+	}{
+		{"select * from t where a = 1", "t", false, []string{"idx_a"},
+			"SELECT * FROM `t` USE INDEX (`idx_a`) WHERE `a`=1"},
+		{"select count(*) from test.t where a > 1 and b < 2", "test.t", true, []string{"idx_a", "idx_b"},
+			"SELECT COUNT(1) FROM `test`.`t` IGNORE INDEX (`idx_a`, `idx_b`) WHERE `a`>1 AND `b`<2"},
+		{"select * from t join s on t.id = s.id where t.a = 1", "T", false, []string{"PRIMARY"},
+			"SELECT * FROM `t` USE INDEX (`PRIMARY`) JOIN `s` ON `t`.`id`=`s`.`id` WHERE `t`.`a`=1"},
+	}
+	for _, h := range hints {
+		q, err := WithIndexHint(h.query, h.table, h.ignore, h.indexes...)
+		if err != nil {
+			t.Fatalf("hint %s failed: err=%v", h.query, err)
+		}
+		if q != h.expect {
+			t.Errorf("hint %s:\n%s\nexpect:\n%s", h.query, q, h.expect)
+		}
+	}
+
+	if _, err := WithIndexHint("select * from s", "t", false, "idx_a"); err == nil {
+		t.Errorf("table t is not in the query")
+	}
+}
+
+func TestGetCreatedTables(t *testing.T) {
+	tables, err := GetCreatedTables("../test-cases/transaction-test/ddl.sql")
+	if err != nil {
+		t.Fatalf("parse failed: err=%v", err)
+	}
+	if len(tables) != 1 || tables[0].QualifiedName() != "`test2`.`t`" || !reflect.DeepEqual(tables[0].Indexes, []string{"j"}) {
+		t.Fatalf("bad tables: %+v", tables)
+	}
+
+	tables, err = GetCreatedTables("../test-cases/correlation/ddl.sql")
+	if err != nil {
+		t.Fatalf("parse failed: err=%v", err)
+	}
+	if len(tables) != 1 || tables[0].QualifiedName() != "`test`.`tbl`" || len(tables[0].Indexes) != 11 || tables[0].Indexes[0] != "idx_asc_100" {
+		t.Fatalf("bad tables: %+v", tables)
+	}
+}
+
+func TestRenameDatabase(t *testing.T) {
+	cases := []struct {
+		query  string
+		expect string
+	}{
+		{"create database test2", "CREATE DATABASE `test2_x`"},
+		{"drop database if exists test2", "DROP DATABASE IF EXISTS `test2_x`"},
+		{"use test2", "USE `test2_x`"},
+		{"select * from test2.t join t2 on t.a = t2.a", "SELECT * FROM `test2_x`.`t` JOIN `t2` ON `t`.`a`=`t2`.`a`"},
+		{"select * from test.t", "select * from test.t"},
+		{"admin check table test2.t", "ADMIN CHECK TABLE `test2_x`.`t`"},
+	}
+	for _, c := range cases {
+		q, err := RenameDatabase(c.query, "test2", "test2_x")
+		if err != nil {
+			t.Fatalf("rename failed: query=%s, err=%v", c.query, err)
+		}
+		if q != c.expect {
+			t.Fatalf("rename failed: query=%s, expect=%s, actual=%s", c.query, c.expect, q)
+		}
+	}
+}
+
+func TestRenameDatabase_Template(t *testing.T) {
+	query := "insert into test2.t values ({{randInt 1 1000}}, '{{randString 16}}', {{seq}})"
+	q, err := RenameDatabase(query, "test2", "test2_x")
+	if err != nil {
+		t.Fatalf("rename failed: %v", err)
+	}
+	if expect := "INSERT INTO `test2_x`.`t` VALUES ({{randInt 1 1000}},'{{randString 16}}',{{seq}})"; q != expect {
+		t.Fatalf("rename failed: expect=%s, actual=%s", expect, q)
+	}
+}
@@ -0,0 +1,38 @@
+package util
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// templatePattern matches the placeholders of a templated statement, like {{randInt 1 1000}}.
+var templatePattern = regexp.MustCompile(`\{\{.*?\}\}`)
+
+// the markers replacing placeholders while a statement is parsed, numbers
+// which are valid wherever a placeholder may be, in a value, a string or a name.
+const templateMarkerBase = 7370000000000000000
+
+// HasTemplate returns whether the statement has placeholders.
+func HasTemplate(query string) bool {
+	return templatePattern.MatchString(query)
+}
+
+// replace the placeholders of the text by markers, so the parser accepts it,
+// returns the placeholders by marker index.
+func markTemplates(text string) (string, []string) {
+	var placeholders []string
+	marked := templatePattern.ReplaceAllStringFunc(text, func(p string) string {
+		placeholders = append(placeholders, p)
+		return fmt.Sprint(templateMarkerBase + len(placeholders) - 1)
+	})
+	return marked, placeholders
+}
+
+// put the placeholders back in place of their markers.
+func unmarkTemplates(text string, placeholders []string) string {
+	for i, p := range placeholders {
+		text = strings.Replace(text, fmt.Sprint(templateMarkerBase+i), p, -1)
+	}
+	return text
+}
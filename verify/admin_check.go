@@ -1,7 +1,9 @@
 package verify
 
 import (
+	"concurrent-sql/util"
 	"database/sql"
+	"fmt"
 	"log"
 )
 
@@ -16,3 +18,15 @@ func (check *AssertNoError) Assert(db *sql.DB) (err error) {
 
 	return
 }
+
+// NewAdminCheckAsserts returns an admin check of each table and each of its indexes.
+func NewAdminCheckAsserts(tables []*util.Table) []Assert {
+	var asserts []Assert
+	for _, t := range tables {
+		asserts = append(asserts, Assert{Type: ASSERT_TYPE_ADMIN, SQL: fmt.Sprintf("ADMIN CHECK TABLE %s;", t.QualifiedName())})
+		for _, index := range t.Indexes {
+			asserts = append(asserts, Assert{Type: ASSERT_TYPE_ADMIN, SQL: fmt.Sprintf("ADMIN CHECK INDEX %s `%s`;", t.QualifiedName(), index)})
+		}
+	}
+	return asserts
+}
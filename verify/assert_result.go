@@ -0,0 +1,51 @@
+package verify
+
+import (
+	"strings"
+	"time"
+)
+
+// AssertResult is the outcome of the last run of an assert, which is the
+// failing one if the assert failed.
+type AssertResult struct {
+	Runs     int
+	Duration time.Duration // of the last run.
+
+	// the result string compared with the expect, and the whole result of the
+	// sql with rows split by \n and columns by \t, empty if the sql failed.
+	Actual string
+	Output string
+
+	// the expect as compared with Actual, normalized like it for the explain assert.
+	Expect string
+	// the operator tree if the sql is an EXPLAIN.
+	Plan *PlanNode
+
+	Failure  string // the mismatch of the result and the expect.
+	Err      error  // the sql failed.
+	Recorded bool   // Actual is the new expect instead of compared, in a record mode.
+}
+
+// the string of the result the expect is compared with.
+func (assert *Assert) actual(result *SqlQueryResult) string {
+	return result.getQueryResultStringFunc(assert)()
+}
+
+func (r *AssertResult) record(assert *Assert, result *SqlQueryResult) {
+	r.Actual = assert.actual(result)
+	r.Output = result.ToOneString()
+	r.Expect = assert.Expect
+	if assert.Type == ASSERT_TYPE_EXPLAIN {
+		r.Expect = result.newPlanNormalizer(assert.IgnoreColumns, assert.EstRowsTolerance).normalizeString(assert.Expect)
+	}
+	if isExplain(assert.query()) {
+		if plan, err := result.Plan(); err == nil {
+			r.Plan = plan
+		}
+	}
+}
+
+func isExplain(query string) bool {
+	query = strings.ToLower(strings.TrimSpace(query))
+	return strings.HasPrefix(query, "explain") || strings.HasPrefix(query, "desc")
+}
@@ -0,0 +1,105 @@
+package verify
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// OperatorEstimation pairs the estimated and actual rows of one operator of an
+// EXPLAIN ANALYZE result.
+type OperatorEstimation struct {
+	Node    *PlanNode
+	EstRows float64
+	ActRows float64
+
+	depth int
+}
+
+// QError is max(est/act, act/est), both counted as at least one row.
+func (e *OperatorEstimation) QError() float64 {
+	est, act := math.Max(e.EstRows, 1), math.Max(e.ActRows, 1)
+	return math.Max(est/act, act/est)
+}
+
+// Estimations lists the estimation of every operator of an analyzed plan, depth first.
+func Estimations(plan *PlanNode) ([]OperatorEstimation, error) {
+	var estimations []OperatorEstimation
+	var err error
+	plan.Walk(func(n *PlanNode, ancestors []*PlanNode) {
+		if err != nil {
+			return
+		}
+		e := OperatorEstimation{Node: n, depth: len(ancestors)}
+		if e.EstRows, err = strconv.ParseFloat(n.EstRows, 64); err != nil {
+			err = fmt.Errorf("bad estRows of %s: %q", n.ID, n.EstRows)
+			return
+		}
+		if e.ActRows, err = strconv.ParseFloat(n.ActRows, 64); err != nil {
+			err = fmt.Errorf("bad actRows of %s: %q, is it an EXPLAIN ANALYZE?", n.ID, n.ActRows)
+			return
+		}
+		estimations = append(estimations, e)
+	})
+	return estimations, err
+}
+
+// whether the operator is the named one, by full id or by operator name. The
+// full id is compared without the (Build)/(Probe) label.
+// sample: TableRowIDScan_18 and TableRowIDScan name TableRowIDScan_18(Probe)
+func (e *OperatorEstimation) is(operator string) bool {
+	id := e.Node.ID
+	if i := strings.Index(id, "("); i > 0 {
+		id = id[:i]
+	}
+	return operator == "" || id == operator || e.Node.Operator == operator
+}
+
+// check the q-error of the named operator, or of all operators if operator is
+// empty. Returns a failure message with the per operator table, or "" if all
+// of them are within maxQError.
+func checkCardinality(plan *PlanNode, operator string, maxQError float64) string {
+	estimations, err := Estimations(plan)
+	if err != nil {
+		return err.Error()
+	}
+
+	found, failed := false, false
+	for i := range estimations {
+		if estimations[i].is(operator) {
+			found = true
+			failed = failed || estimations[i].QError() > maxQError
+		}
+	}
+	if !found {
+		return fmt.Sprintf("operator %s not found in plan:\n%s", operator, plan)
+	}
+	if !failed {
+		return ""
+	}
+
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "id\testRows\tactRows\tq-error\t")
+	for i := range estimations {
+		e := &estimations[i]
+		mark := ""
+		if e.is(operator) && e.QError() > maxQError {
+			mark = "<- exceeds"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%.2f\t%s\n", strings.Repeat("  ", e.depth)+e.Node.ID, e.Node.EstRows, e.Node.ActRows, e.QError(), mark)
+	}
+	_ = w.Flush()
+	return fmt.Sprintf("q-error exceeds %.2f:\n%s", maxQError, strings.TrimRight(buf.String(), "\n"))
+}
+
+// the sql of a cardinality assert is run under EXPLAIN ANALYZE.
+func explainAnalyzeSQL(query string) string {
+	if fields := strings.Fields(strings.ToLower(query)); len(fields) >= 2 && fields[0] == "explain" && fields[1] == "analyze" {
+		return query
+	}
+	return "EXPLAIN ANALYZE " + query
+}
@@ -0,0 +1,99 @@
+package verify
+
+import (
+	"concurrent-sql/util"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// indexes of the table in the current database, or in its schema if given as db.t
+func tableIndexes(db *sql.DB, table string) ([]string, error) {
+	query := "SELECT DISTINCT INDEX_NAME FROM information_schema.statistics WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?"
+	args := []interface{}{table}
+	if i := strings.Index(table, "."); i >= 0 {
+		query = "SELECT DISTINCT INDEX_NAME FROM information_schema.statistics WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?"
+		args = []interface{}{table[:i], table[i+1:]}
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var indexes []string
+	for rows.Next() {
+		var index string
+		if err := rows.Scan(&index); err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, rows.Err()
+}
+
+// the statements compared by the consistency assert: sql, sqls, and if
+// index_variants is given, sql reading the table by each of its indexes and by
+// none of them.
+func (assert *Assert) consistencyQueries(db *sql.DB) ([]string, error) {
+	queries := append([]string{assert.SQL}, assert.SQLs...)
+	if assert.IndexVariants == "" {
+		return queries, nil
+	}
+
+	indexes, err := tableIndexes(db, assert.IndexVariants)
+	if err != nil {
+		return nil, err
+	}
+	if len(indexes) == 0 {
+		return nil, fmt.Errorf("no index found on table %s", assert.IndexVariants)
+	}
+	for _, index := range indexes {
+		q, err := util.WithIndexHint(assert.SQL, assert.IndexVariants, false, index)
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, q)
+	}
+	q, err := util.WithIndexHint(assert.SQL, assert.IndexVariants, true, indexes...)
+	if err != nil {
+		return nil, err
+	}
+	return append(queries, q), nil
+}
+
+// checkConsistency runs all statements of the consistency assert and compares
+// each result set with the first one. Returns a failure message or "" if all
+// of them are equal.
+func (assert *Assert) checkConsistency(db *sql.DB, logger *log.Logger) (string, error) {
+	queries, err := assert.consistencyQueries(db)
+	if err != nil {
+		return "", err
+	}
+	if len(queries) < 2 {
+		return "", errors.New("consistency assert needs at least two statements, set sqls or index_variants")
+	}
+
+	ordered := util.HasOrderBy(queries[0])
+	if assert.Ordered != nil {
+		ordered = *assert.Ordered
+	}
+	first, err := GetQueryResult(db, queries[0])
+	if err != nil {
+		return "", err
+	}
+	expect := first.ToResultString()
+	for _, q := range queries[1:] {
+		result, err := GetQueryResult(db, q)
+		if err != nil {
+			return "", err
+		}
+		if failure := result.compareRows(expect, ordered, assert.Tolerance); failure != "" {
+			return fmt.Sprintf("result of %s\nis not equals to the result of %s\n%s", q, queries[0], failure), nil
+		}
+		logger.Println("consistent with the first statement:", q)
+	}
+	return "", nil
+}
@@ -0,0 +1,100 @@
+package verify
+
+import (
+	"concurrent-sql/util"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+func (assert *Assert) hasAlternativeExpect() bool {
+	return assert.ExpectRegex != "" || assert.ExpectContains != "" || assert.ExpectGlob != "" || len(assert.ExpectAnyOf) > 0
+}
+
+// check expect_any_of, expect_regex, expect_contains and expect_glob, every one
+// given must hold. Returns a failure message or "" if all of them hold.
+func (assert *Assert) compareAlternativeExpect(result *SqlQueryResult) string {
+	if len(assert.ExpectAnyOf) > 0 {
+		var failures []string
+		for i, expect := range assert.ExpectAnyOf {
+			failure := assert.compareExpect(result, expect)
+			if failure == "" {
+				failures = nil
+				break
+			}
+			failures = append(failures, fmt.Sprintf("expect_any_of[%d]: %s", i, failure))
+		}
+		if failures != nil {
+			return "Result is not equals to any of the expects\n" + strings.Join(failures, "\n")
+		}
+	}
+
+	stringFunc := result.getQueryResultStringFunc(assert)
+	actual := stringFunc()
+	if assert.ExpectRegex != "" {
+		re, err := regexp.Compile(assert.ExpectRegex)
+		if err != nil {
+			return fmt.Sprintf("bad expect_regex %q: %v", assert.ExpectRegex, err)
+		}
+		if !re.MatchString(actual) {
+			return fmt.Sprintf("Result does not match expect_regex %q\nActual Result:\n%s", assert.ExpectRegex, actual)
+		}
+	}
+	if assert.ExpectContains != "" && !strings.Contains(actual, assert.ExpectContains) {
+		return fmt.Sprintf("Result does not contain expect_contains %q\nActual Result:\n%s", assert.ExpectContains, actual)
+	}
+	if assert.ExpectGlob != "" && !globRegexp(assert.ExpectGlob).MatchString(actual) {
+		return fmt.Sprintf("Result does not match expect_glob %q\nActual Result:\n%s", assert.ExpectGlob, actual)
+	}
+	return ""
+}
+
+// compile a glob matching the whole string, * is any text including \n and \t, ? any one character.
+func globRegexp(glob string) *regexp.Regexp {
+	var buf strings.Builder
+	buf.WriteString(`(?s)^`)
+	for _, r := range glob {
+		switch r {
+		case '*':
+			buf.WriteString(`.*`)
+		case '?':
+			buf.WriteString(`.`)
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	buf.WriteString(`$`)
+	return regexp.MustCompile(buf.String())
+}
+
+// ExpectError is an error the sql of an assert must fail with.
+type ExpectError struct {
+	Code    uint16 `json:"code,omitempty"`    // MySQL error code, 0 for any.
+	Message string `json:"message,omitempty"` // regex the error message must match.
+}
+
+func (e *ExpectError) String() string {
+	return fmt.Sprintf("code=%d message=%q", e.Code, e.Message)
+}
+
+// check the error of the query, returns a failure message or "" if it is the expected one.
+func (e *ExpectError) check(err error) string {
+	if err == nil {
+		return fmt.Sprintf("expect error %s, but the sql succeeded", e)
+	}
+	if e.Code != 0 {
+		if code, ok := util.ErrorCode(err); !ok || code != e.Code {
+			return fmt.Sprintf("expect error %s, got: %v", e, err)
+		}
+	}
+	if e.Message != "" {
+		re, reErr := regexp.Compile(e.Message)
+		if reErr != nil {
+			return fmt.Sprintf("bad expect_error message %q: %v", e.Message, reErr)
+		}
+		if !re.MatchString(err.Error()) {
+			return fmt.Sprintf("expect error %s, got: %v", e, err)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,52 @@
+package verify
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// LoadExpectFiles reads the expect of each assert with an expect_file, relative
+// to dir. In a record mode a missing file is an empty expect, so it can be
+// recorded, otherwise it is an error.
+func (v *Verify) LoadExpectFiles(dir string) error {
+	for i := range v.Asserts {
+		as := &v.Asserts[i]
+		if as.ExpectFile == "" {
+			continue
+		}
+		if as.Expect != "" {
+			return fmt.Errorf("assert %d has both expect and expect_file", i)
+		}
+		as.expectPath = path.Join(dir, as.ExpectFile)
+		expect, err := readExpectFile(as.expectPath)
+		if os.IsNotExist(err) && v.Record != RecordNone {
+			v.logger().Printf("expect file %s not found, the expect is empty", as.expectPath)
+			continue
+		} else if err != nil {
+			return err
+		}
+		as.Expect = expect
+	}
+	return nil
+}
+
+// the raw result in an expect file, without the newline the file ends with.
+func readExpectFile(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
+
+// WriteExpectFile writes the expect raw, ending with a newline, creating the
+// directory of the file if needed.
+func WriteExpectFile(file, expect string) error {
+	if err := os.MkdirAll(path.Dir(file), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, []byte(expect+"\n"), 0644)
+}
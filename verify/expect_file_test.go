@@ -0,0 +1,56 @@
+package verify
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestVerify_LoadExpectFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "expect-file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	plan := "TableReader\troot\tdata:TableScan\n└─TableScan\tcop\ttable:t"
+	if err := WriteExpectFile(path.Join(dir, "expected/q1.txt"), plan); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	v := Verify{Asserts: []Assert{
+		{Type: ASSERT_TYPE_EXPLAIN, ExpectFile: "expected/q1.txt"},
+		{Type: ASSERT_TYPE_EXPLAIN, ExpectFile: "expected/q2.txt"},
+	}}
+	if err := v.LoadExpectFiles(dir); !os.IsNotExist(err) {
+		t.Fatalf("a missing expect file should fail out of the record mode: %v", err)
+	}
+	v = Verify{Record: RecordMissing, Asserts: []Assert{
+		{Type: ASSERT_TYPE_EXPLAIN, ExpectFile: "expected/q1.txt"},
+		{Type: ASSERT_TYPE_EXPLAIN, ExpectFile: "expected/q2.txt"},
+	}}
+	if err := v.LoadExpectFiles(dir); err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if v.Asserts[0].Expect != plan || v.Asserts[1].Expect != "" {
+		t.Fatalf("bad expects: %q, %q", v.Asserts[0].Expect, v.Asserts[1].Expect)
+	}
+
+	// recorded expects are written to the expect files.
+	v.Results = []AssertResult{{Recorded: true, Actual: plan}, {Recorded: true, Actual: "Point_Get\troot\ttable:t"}}
+	updates := v.Updates(0)
+	if len(updates) != 1 || updates[0].File != path.Join(dir, "expected/q2.txt") {
+		t.Fatalf("bad updates: %+v", updates)
+	}
+	if err := UpdateVerificationFile(path.Join(dir, "verification.json"), updates); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if expect, err := readExpectFile(path.Join(dir, "expected/q2.txt")); err != nil || expect != "Point_Get\troot\ttable:t" {
+		t.Fatalf("bad expect file: %q, %v", expect, err)
+	}
+
+	v = Verify{Asserts: []Assert{{Expect: "1", ExpectFile: "expected/q1.txt"}}}
+	if err := v.LoadExpectFiles(dir); err == nil {
+		t.Fatalf("expect and expect_file accepted together")
+	}
+}
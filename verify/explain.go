@@ -1,17 +1,36 @@
 package verify
 
-import "database/sql"
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
 
 type PlanAssert struct {
 	SQL    string
 	Expect string
+	Checks []PlanCheck
 }
 
 func (pa *PlanAssert) Assert(db *sql.DB) error {
-	_, err := db.Exec(pa.SQL)
+	result, err := GetQueryResult(db, pa.SQL)
 	if err != nil {
 		return err
 	}
 
+	plan, err := result.Plan()
+	if err != nil {
+		return err
+	}
+	if failure := checkPlan(plan, pa.Checks); failure != "" {
+		return errors.New(failure)
+	}
+
+	if pa.Expect != "" {
+		if scanType := result.getPlanScanType(); scanType != pa.Expect {
+			return fmt.Errorf("scan type %s is not equals to expect %s", scanType, pa.Expect)
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,138 @@
+package verify
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// operator ids in the id and operator info columns, sample: TableReader_22, data:TableScan_4
+var operatorIDPattern = regexp.MustCompile(`\b([A-Z][a-z]+[A-Za-z]*)_[0-9]+\b`)
+
+// strip operator id suffixes, sample: "TableReader_22" into "TableReader"
+func stripOperatorIDs(s string) string {
+	return operatorIDPattern.ReplaceAllString(s, "$1")
+}
+
+// planNormalizer rewrites explain rows so that they survive operator renumbering
+// and cost changes between TiDB releases.
+type planNormalizer struct {
+	dropped   map[int]bool // indexes of the ignored columns.
+	estRows   int          // index of the estRows column, -1 if absent or ignored.
+	tolerance float64      // max relative difference of estRows, 0 to compare as text.
+	columns   int          // column count of the result.
+}
+
+func (result *SqlQueryResult) newPlanNormalizer(ignoreColumns []string, tolerance float64) *planNormalizer {
+	n := &planNormalizer{dropped: map[int]bool{}, estRows: -1, tolerance: tolerance, columns: len(result.header)}
+	for _, name := range ignoreColumns {
+		for i, h := range result.header {
+			if strings.EqualFold(h, name) {
+				n.dropped[i] = true
+			}
+		}
+		// allow estRows for the count column of older versions and the other way round.
+		if i := result.planColumnIndex(name); i >= 0 {
+			n.dropped[i] = true
+		}
+	}
+	if i := result.planColumnIndex("estRows"); i >= 0 && !n.dropped[i] {
+		n.estRows = i
+	}
+	return n
+}
+
+// normalize one row, the kept columns in order and the index of estRows in them.
+// A row of an expect string may already miss the ignored columns, as printed
+// by ToNormalizedString.
+func (n *planNormalizer) normalize(row []string) ([]string, int) {
+	var out []string
+	estRows := -1
+	full := len(row) == n.columns
+	// the index of estRows in the row, a row missing the ignored columns has
+	// them missing before it too.
+	estIndex := n.estRows
+	if !full && estIndex >= 0 {
+		for i := range n.dropped {
+			if i < n.estRows {
+				estIndex--
+			}
+		}
+	}
+	for i, col := range row {
+		if full && n.dropped[i] {
+			continue
+		}
+		if i == estIndex {
+			estRows = len(out)
+		}
+		out = append(out, stripOperatorIDs(col))
+	}
+	return out, estRows
+}
+
+func (n *planNormalizer) normalizeString(s string) string {
+	var lines []string
+	for _, row := range splitResultString(s) {
+		cols, _ := n.normalize(row)
+		lines = append(lines, strings.Join(cols, "\t"))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// equal compares the normalized expect and actual strings, estRows within the tolerance.
+func (n *planNormalizer) equal(expect, actual string) bool {
+	if n.tolerance <= 0 || n.estRows < 0 {
+		return n.normalizeString(expect) == n.normalizeString(actual)
+	}
+
+	expectRows, actualRows := splitResultString(expect), splitResultString(actual)
+	if len(expectRows) != len(actualRows) {
+		return false
+	}
+	for i := range expectRows {
+		e, estCol := n.normalize(expectRows[i])
+		a, _ := n.normalize(actualRows[i])
+		if len(e) != len(a) {
+			return false
+		}
+		for j := range e {
+			if j == estCol {
+				if !floatNear(e[j], a[j], n.tolerance) {
+					return false
+				}
+			} else if e[j] != a[j] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// whether two numbers differ by at most tolerance relative to the larger one.
+func floatNear(expect, actual string, tolerance float64) bool {
+	e, err1 := strconv.ParseFloat(expect, 64)
+	a, err2 := strconv.ParseFloat(actual, 64)
+	if err1 != nil || err2 != nil {
+		return expect == actual
+	}
+	return math.Abs(e-a) <= tolerance*math.Max(math.Abs(e), math.Abs(a))
+}
+
+// split a ToOneString style result into rows and columns.
+func splitResultString(s string) [][]string {
+	var rows [][]string
+	for _, line := range strings.Split(s, "\n") {
+		rows = append(rows, strings.Split(line, "\t"))
+	}
+	return rows
+}
+
+// ToNormalizedString is ToOneString with operator ids stripped and the ignored columns dropped.
+func (result *SqlQueryResult) ToNormalizedString(ignoreColumns ...string) string {
+	if result.data == nil || result.header == nil {
+		return "no result"
+	}
+	return result.newPlanNormalizer(ignoreColumns, 0).normalizeString(result.ToOneString())
+}
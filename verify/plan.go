@@ -0,0 +1,264 @@
+package verify
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// PlanNode is one operator of an EXPLAIN result. Children are built from the
+// └─/├─ indentation of the id column.
+type PlanNode struct {
+	ID           string // operator id as printed, e.g. TableReader_22
+	Operator     string // operator name without the id suffix, e.g. TableReader
+	EstRows      string
+	Task         string
+	AccessObject string
+	OperatorInfo string
+	ActRows      string // only in EXPLAIN ANALYZE results.
+	Children     []*PlanNode
+
+	// the other columns as printed, used to print the tree back.
+	columns []string
+}
+
+// names of the explain columns, older TiDB versions print count instead of estRows.
+var planColumns = map[string][]string{
+	"id":             {"id"},
+	"estRows":        {"estrows", "count"},
+	"task":           {"task"},
+	"access object":  {"access object"},
+	"operator info":  {"operator info"},
+	"actRows":        {"actrows"},
+	"execution info": {"execution info"},
+}
+
+var executionRowsPattern = regexp.MustCompile(`\brows:([0-9]+)`)
+
+// locate the explain columns in the result header, -1 if absent.
+func (result *SqlQueryResult) planColumnIndex(column string) int {
+	for i, h := range result.header {
+		for _, name := range planColumns[column] {
+			if strings.ToLower(h) == name {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Plan parses an EXPLAIN result into an operator tree and returns its root.
+func (result *SqlQueryResult) Plan() (*PlanNode, error) {
+	if result.data == nil || result.header == nil {
+		return nil, errors.New("no result")
+	}
+	idCol := result.planColumnIndex("id")
+	if idCol < 0 {
+		return nil, errors.New("not an explain result: no id column")
+	}
+	column := func(row [][]byte, name string) string {
+		if i := result.planColumnIndex(name); i >= 0 && i < len(row) {
+			return string(row[i])
+		}
+		return ""
+	}
+
+	var root *PlanNode
+	// stack[d] is the last node seen at depth d.
+	var stack []*PlanNode
+	for _, row := range result.data {
+		depth, id := splitPlanIndent(string(row[idCol]))
+		node := &PlanNode{
+			ID:           id,
+			Operator:     operatorName(id),
+			EstRows:      column(row, "estRows"),
+			Task:         column(row, "task"),
+			AccessObject: column(row, "access object"),
+			OperatorInfo: column(row, "operator info"),
+			ActRows:      column(row, "actRows"),
+		}
+		if node.ActRows == "" {
+			// older versions only print rows:N in the execution info.
+			if m := executionRowsPattern.FindStringSubmatch(column(row, "execution info")); m != nil {
+				node.ActRows = m[1]
+			}
+		}
+		for i, col := range row {
+			if i != idCol {
+				node.columns = append(node.columns, string(col))
+			}
+		}
+		if depth == 0 {
+			if root != nil {
+				return nil, fmt.Errorf("more than one root operator: %s, %s", root.ID, id)
+			}
+			root = node
+		} else {
+			if depth > len(stack) {
+				return nil, fmt.Errorf("bad plan indentation at %s", id)
+			}
+			parent := stack[depth-1]
+			parent.Children = append(parent.Children, node)
+		}
+		stack = append(stack[:depth], node)
+	}
+	if root == nil {
+		return nil, errors.New("empty plan")
+	}
+	return root, nil
+}
+
+// split the tree prefix of an id column into depth and operator id.
+// sample: "  └─TableScan_19" into depth=2, id=TableScan_19
+func splitPlanIndent(col string) (int, string) {
+	prefix := 0
+	for i, r := range col {
+		if r != ' ' && r != '│' && r != '├' && r != '└' && r != '─' {
+			return prefix / 2, col[i:]
+		}
+		prefix++
+	}
+	return prefix / 2, ""
+}
+
+// strip the id suffix and (Build)/(Probe) labels from an operator id.
+// sample: "IndexRangeScan_8(Build)" into IndexRangeScan
+func operatorName(id string) string {
+	if i := strings.Index(id, "("); i > 0 {
+		id = id[:i]
+	}
+	if i := strings.LastIndex(id, "_"); i > 0 && isDigits(id[i+1:]) {
+		id = id[:i]
+	}
+	return id
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Walk visits the node and all its descendants, depth first. The ancestors of
+// each visited node are passed from the root down.
+func (node *PlanNode) Walk(visit func(n *PlanNode, ancestors []*PlanNode)) {
+	var walk func(n *PlanNode, ancestors []*PlanNode)
+	walk = func(n *PlanNode, ancestors []*PlanNode) {
+		visit(n, ancestors)
+		ancestors = append(ancestors, n)
+		for _, c := range n.Children {
+			walk(c, ancestors)
+		}
+	}
+	walk(node, nil)
+}
+
+// String prints the tree in the explain indentation, one operator per line.
+func (node *PlanNode) String() string {
+	var buf bytes.Buffer
+	var print func(n *PlanNode, indent string, last bool, depth int)
+	print = func(n *PlanNode, indent string, last bool, depth int) {
+		if buf.Len() > 0 {
+			buf.WriteString("\n")
+		}
+		childIndent := indent
+		if depth > 0 {
+			if last {
+				buf.WriteString(indent + "└─")
+				childIndent += "  "
+			} else {
+				buf.WriteString(indent + "├─")
+				childIndent += "│ "
+			}
+		}
+		buf.WriteString(n.ID)
+		for _, col := range n.columns {
+			buf.WriteString("\t" + col)
+		}
+		for i, c := range n.Children {
+			print(c, childIndent, i == len(n.Children)-1, depth+1)
+		}
+	}
+	print(node, "", true, 0)
+	return buf.String()
+}
+
+// PlanCheck is one structured expectation on a plan tree, used by the plan assert.
+type PlanCheck struct {
+	Operator string `json:"operator"`         // operator name, the id suffix is ignored.
+	Object   string `json:"object,omitempty"` // text in the access object or operator info, e.g. index:idx_a
+	Task     string `json:"task,omitempty"`   // root, cop, cop[tikv]...
+	Under    string `json:"under,omitempty"`  // an ancestor operator the match must be under.
+	Absent   bool   `json:"absent,omitempty"` // the operator must not appear anywhere.
+}
+
+func (check *PlanCheck) String() string {
+	str := check.Operator
+	if check.Object != "" {
+		str += " on " + check.Object
+	}
+	if check.Task != "" {
+		str += " in " + check.Task
+	}
+	if check.Under != "" {
+		str += " under " + check.Under
+	}
+	if check.Absent {
+		str = "no " + str
+	}
+	return str
+}
+
+func (check *PlanCheck) matchNode(n *PlanNode, ancestors []*PlanNode) bool {
+	if n.Operator != operatorName(check.Operator) {
+		return false
+	}
+	if check.Object != "" && !strings.Contains(n.AccessObject, check.Object) && !strings.Contains(n.OperatorInfo, check.Object) {
+		return false
+	}
+	if check.Task != "" && n.Task != check.Task {
+		return false
+	}
+	if check.Under != "" {
+		for _, a := range ancestors {
+			if a.Operator == operatorName(check.Under) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Check returns whether the plan satisfies the expectation.
+func (check *PlanCheck) Check(plan *PlanNode) bool {
+	found := false
+	plan.Walk(func(n *PlanNode, ancestors []*PlanNode) {
+		if !found && check.matchNode(n, ancestors) {
+			found = true
+		}
+	})
+	return found != check.Absent
+}
+
+// run all checks on the plan, returns a failure message or "" if all of them pass.
+func checkPlan(plan *PlanNode, checks []PlanCheck) string {
+	var failed []string
+	for i := range checks {
+		if !checks[i].Check(plan) {
+			failed = append(failed, "  "+checks[i].String())
+		}
+	}
+	if len(failed) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("plan check failed:\n%s\nActual Plan:\n%s", strings.Join(failed, "\n"), plan)
+}
@@ -0,0 +1,170 @@
+package verify
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// planStep is one step of a plan pattern, sample: TableScan(table:t, keep order:true)
+type planStep struct {
+	any      bool     // "*", exactly one operator of any kind.
+	skip     bool     // "**", zero or more operators.
+	operator string   // operator name, may contain * wildcards.
+	attrs    []string // text each required in the access object or operator info.
+}
+
+// PlanPattern is a path in the plan tree from the root down, one step per level.
+// sample: Limit > TableReader > * > TableScan(table:unknown_correlation, keep order:true)
+//
+// A step is an operator name with an optional list of attributes, "*" for any
+// single operator, or "**" for any number of levels. Operator id suffixes like
+// _11 are ignored.
+type PlanPattern struct {
+	text  string
+	steps []planStep
+}
+
+// ParsePlanPattern parses one pattern line.
+func ParsePlanPattern(text string) (*PlanPattern, error) {
+	p := &PlanPattern{text: strings.TrimSpace(text)}
+	for _, s := range splitOutsideParens(p.text, '>') {
+		step, err := parsePlanStep(strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("bad plan pattern %q: %v", p.text, err)
+		}
+		p.steps = append(p.steps, step)
+	}
+	return p, nil
+}
+
+func parsePlanStep(s string) (planStep, error) {
+	switch s {
+	case "":
+		return planStep{}, errors.New("empty step")
+	case "*":
+		return planStep{any: true}, nil
+	case "**":
+		return planStep{skip: true}, nil
+	}
+
+	step := planStep{operator: s}
+	if i := strings.Index(s, "("); i >= 0 {
+		if !strings.HasSuffix(s, ")") {
+			return step, fmt.Errorf("unclosed attributes in %s", s)
+		}
+		step.operator = strings.TrimSpace(s[:i])
+		for _, attr := range splitOutsideParens(s[i+1:len(s)-1], ',') {
+			if attr = strings.TrimSpace(attr); attr != "" {
+				step.attrs = append(step.attrs, attr)
+			}
+		}
+	}
+	step.operator = operatorName(step.operator)
+	if _, err := path.Match(step.operator, ""); err != nil {
+		return step, err
+	}
+	return step, nil
+}
+
+// split s by sep, but not inside (), [] or {}.
+func splitOutsideParens(s string, sep rune) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range s {
+		switch r {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case sep:
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func (step *planStep) match(n *PlanNode) bool {
+	if step.any {
+		return true
+	}
+	if ok, _ := path.Match(step.operator, n.Operator); !ok {
+		return false
+	}
+	for _, attr := range step.attrs {
+		if !strings.Contains(n.AccessObject, attr) && !strings.Contains(n.OperatorInfo, attr) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchSteps(steps []planStep, n *PlanNode) bool {
+	if len(steps) == 0 {
+		return true
+	}
+	if steps[0].skip {
+		if matchSteps(steps[1:], n) {
+			return true
+		}
+		for _, c := range n.Children {
+			if matchSteps(steps, c) {
+				return true
+			}
+		}
+		return false
+	}
+	if !steps[0].match(n) {
+		return false
+	}
+	if len(steps) == 1 {
+		return true
+	}
+	for _, c := range n.Children {
+		if matchSteps(steps[1:], c) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match returns whether the path of the pattern exists in the plan, starting at the root.
+func (p *PlanPattern) Match(plan *PlanNode) bool {
+	return matchSteps(p.steps, plan)
+}
+
+func (p *PlanPattern) String() string {
+	return p.text
+}
+
+// match the plan against every non empty line of expect, returns a failure
+// message or "" if all of them match. An expect without patterns fails, it
+// would check nothing.
+func matchPlan(plan *PlanNode, expect string) string {
+	var failed []string
+	patterns := 0
+	for _, line := range strings.Split(expect, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		pattern, err := ParsePlanPattern(line)
+		if err != nil {
+			return err.Error()
+		}
+		patterns++
+		if !pattern.Match(plan) {
+			failed = append(failed, "  "+pattern.String())
+		}
+	}
+	if patterns == 0 {
+		return "plan_match needs at least one pattern"
+	}
+	if len(failed) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("plan does not match:\n%s\nActual Plan:\n%s", strings.Join(failed, "\n"), plan)
+}
@@ -0,0 +1,218 @@
+package verify
+
+import (
+	"strings"
+	"testing"
+)
+
+// build a query result from tab separated rows, like the expect strings.
+func newTestResult(header []string, rows string) *SqlQueryResult {
+	var data [][][]byte
+	for _, line := range strings.Split(rows, "\n") {
+		var row [][]byte
+		for _, col := range strings.Split(line, "\t") {
+			row = append(row, []byte(col))
+		}
+		data = append(data, row)
+	}
+	return &SqlQueryResult{data: data, header: header}
+}
+
+var oldExplainHeader = []string{"id", "count", "task", "operator info"}
+
+const samplePlan = "Limit_11\t1.00\troot\toffset:0, count:1\n" +
+	"└─TableReader_22\t1.00\troot\tdata:Limit_21\n" +
+	"  └─Limit_21\t1.00\tcop\toffset:0, count:1\n" +
+	"    └─Selection_20\t1.00\tcop\teq(test2.unknown_correlation.a, 2)\n" +
+	"      └─TableScan_19\t4.17\tcop\ttable:unknown_correlation, range:[-inf,+inf], keep order:true"
+
+func TestSqlQueryResult_Plan(t *testing.T) {
+	plan, err := newTestResult(oldExplainHeader, samplePlan).Plan()
+	if err != nil {
+		t.Fatalf("parse failed: err=%v", err)
+	}
+	if plan.Operator != "Limit" || plan.ID != "Limit_11" {
+		t.Fatalf("bad root: %s", plan.ID)
+	}
+	scan := plan.Children[0].Children[0].Children[0].Children[0]
+	if scan.Operator != "TableScan" || scan.EstRows != "4.17" || scan.Task != "cop" {
+		t.Fatalf("bad leaf: %+v", scan)
+	}
+	if plan.String() != samplePlan {
+		t.Fatalf("bad string:\n%s", plan.String())
+	}
+}
+
+func TestSqlQueryResult_PlanSiblings(t *testing.T) {
+	header := []string{"id", "estRows", "task", "access object", "operator info"}
+	rows := "TopN_9\t1.00\troot\t\ttest.t.id, offset:0, count:1\n" +
+		"└─IndexLookUp_20\t1.00\troot\t\t\n" +
+		"  ├─IndexRangeScan_17(Build)\t10.00\tcop[tikv]\ttable:t, index:idx_a(a)\trange:[1,1], keep order:false\n" +
+		"  └─TopN_19(Probe)\t1.00\tcop[tikv]\t\ttest.t.id, offset:0, count:1\n" +
+		"    └─TableRowIDScan_18\t10.00\tcop[tikv]\ttable:t\tkeep order:false"
+	plan, err := newTestResult(header, rows).Plan()
+	if err != nil {
+		t.Fatalf("parse failed: err=%v", err)
+	}
+	lookup := plan.Children[0]
+	if len(lookup.Children) != 2 {
+		t.Fatalf("IndexLookUp should have 2 children, got %d", len(lookup.Children))
+	}
+	if lookup.Children[0].Operator != "IndexRangeScan" || lookup.Children[0].AccessObject != "table:t, index:idx_a(a)" {
+		t.Fatalf("bad build side: %+v", lookup.Children[0])
+	}
+	if plan.String() != rows {
+		t.Fatalf("bad string:\n%s", plan.String())
+	}
+
+	checks := []struct {
+		check PlanCheck
+		pass  bool
+	}{
+		{PlanCheck{Operator: "IndexRangeScan", Object: "idx_a", Under: "TopN"}, true},
+		{PlanCheck{Operator: "IndexRangeScan", Object: "idx_b"}, false},
+		{PlanCheck{Operator: "TableRowIDScan", Under: "TopN_19", Task: "cop[tikv]"}, true},
+		{PlanCheck{Operator: "IndexLookUp", Under: "TopN"}, true},
+		{PlanCheck{Operator: "TopN", Under: "IndexRangeScan"}, false},
+		{PlanCheck{Operator: "HashJoin", Absent: true}, true},
+		{PlanCheck{Operator: "IndexLookUp", Absent: true}, false},
+	}
+	for _, c := range checks {
+		if c.check.Check(plan) != c.pass {
+			t.Errorf("check %s should be %v", c.check.String(), c.pass)
+		}
+	}
+}
+
+func TestPlanPattern_Match(t *testing.T) {
+	plan, err := newTestResult(oldExplainHeader, samplePlan).Plan()
+	if err != nil {
+		t.Fatalf("parse failed: err=%v", err)
+	}
+
+	patterns := []struct {
+		pattern string
+		match   bool
+	}{
+		{"Limit > TableReader > * > * > TableScan(table:unknown_correlation, keep order:true)", true},
+		{"Limit_3 > TableReader_5 > Limit", true},
+		{"Limit > TableReader > * > TableScan", false},
+		{"Limit > TableReader > ** > TableScan(table:unknown_correlation, keep order:true)", true},
+		{"Limit > ** > TableScan(range:[-inf,+inf])", true},
+		{"** > Selection > TableScan", true},
+		{"** > Selection > TableScan(keep order:false)", false},
+		{"** > *Scan", true},
+		{"** > IndexScan", false},
+		{"TableReader", false},
+		{"Limit > **", true},
+	}
+	for _, p := range patterns {
+		pattern, err := ParsePlanPattern(p.pattern)
+		if err != nil {
+			t.Fatalf("parse %s failed: err=%v", p.pattern, err)
+		}
+		if pattern.Match(plan) != p.match {
+			t.Errorf("pattern %s should match: %v", p.pattern, p.match)
+		}
+	}
+
+	if failure := matchPlan(plan, "Limit > TableReader\n\n** > TableScan\n"); failure != "" {
+		t.Errorf("patterns should match: %s", failure)
+	}
+	for _, expect := range []string{"", " \n\t\n"} {
+		if failure := matchPlan(plan, expect); failure != "plan_match needs at least one pattern" {
+			t.Errorf("an expect without patterns should fail: %q", failure)
+		}
+	}
+
+	if _, err := ParsePlanPattern("Limit > > TableScan"); err == nil {
+		t.Errorf("empty step should be an error")
+	}
+	if _, err := ParsePlanPattern("TableScan(keep order:true"); err == nil {
+		t.Errorf("unclosed attributes should be an error")
+	}
+}
+
+func TestAssert_CompareExplain(t *testing.T) {
+	result := newTestResult(oldExplainHeader, samplePlan)
+	renumbered := "Limit_7\t1.00\troot\toffset:0, count:1\n" +
+		"└─TableReader_17\t1.00\troot\tdata:Limit_16\n" +
+		"  └─Limit_16\t1.00\tcop\toffset:0, count:1\n" +
+		"    └─Selection_15\t1.00\tcop\teq(test2.unknown_correlation.a, 2)\n" +
+		"      └─TableScan_14\t4.50\tcop\ttable:unknown_correlation, range:[-inf,+inf], keep order:true"
+	withoutID := strings.Replace(result.ToNormalizedString("id"), "4.17", "4.50", 1)
+
+	asserts := []struct {
+		assert Assert
+		equals bool
+	}{
+		{Assert{Type: ASSERT_TYPE_EXPLAIN, Expect: samplePlan}, true},
+		{Assert{Type: ASSERT_TYPE_EXPLAIN, Expect: renumbered}, false},
+		{Assert{Type: ASSERT_TYPE_EXPLAIN, Expect: renumbered, IgnoreColumns: []string{"estRows"}}, true},
+		{Assert{Type: ASSERT_TYPE_EXPLAIN, Expect: result.ToNormalizedString("estRows"), IgnoreColumns: []string{"estRows"}}, true},
+		{Assert{Type: ASSERT_TYPE_EXPLAIN, Expect: renumbered, EstRowsTolerance: 0.1}, true},
+		{Assert{Type: ASSERT_TYPE_EXPLAIN, Expect: renumbered, EstRowsTolerance: 0.05}, false},
+		// the expect misses the ignored id in front of estRows.
+		{Assert{Type: ASSERT_TYPE_EXPLAIN, Expect: withoutID, IgnoreColumns: []string{"id"}, EstRowsTolerance: 0.1}, true},
+		{Assert{Type: ASSERT_TYPE_EXPLAIN, Expect: withoutID, IgnoreColumns: []string{"id"}, EstRowsTolerance: 0.05}, false},
+		{Assert{Type: "query", Expect: renumbered, IgnoreColumns: []string{"count"}}, false},
+	}
+	for i, a := range asserts {
+		if failure := a.assert.compare(result); (failure == "") != a.equals {
+			t.Errorf("assert %d should be equals: %v, %s", i, a.equals, failure)
+		}
+	}
+
+	if str := result.ToNormalizedString("count"); !strings.HasPrefix(str, "Limit\troot\toffset:0, count:1\n└─TableReader\troot\tdata:Limit\n") {
+		t.Errorf("bad normalized string:\n%s", str)
+	}
+}
+
+func TestCheckCardinality(t *testing.T) {
+	header := []string{"id", "estRows", "actRows", "task", "access object", "execution info", "operator info", "memory", "disk"}
+	rows := "TopN_9\t1.00\t1\troot\t\ttime:1ms, loops:2\ttest.t.id, offset:0, count:1\t1 KB\tN/A\n" +
+		"└─IndexLookUp_20\t10.00\t400\troot\t\ttime:1ms, loops:2\t\t10 KB\tN/A\n" +
+		"  ├─IndexRangeScan_17(Build)\t10.00\t400\tcop[tikv]\ttable:t, index:idx_a(a)\ttime:1ms, loops:3\trange:[1,1], keep order:false\tN/A\tN/A\n" +
+		"  └─TableRowIDScan_18(Probe)\t10.00\t40\tcop[tikv]\ttable:t\ttime:1ms, loops:3\tkeep order:false\tN/A\tN/A"
+	plan, err := newTestResult(header, rows).Plan()
+	if err != nil {
+		t.Fatalf("parse failed: err=%v", err)
+	}
+	estimations, err := Estimations(plan)
+	if err != nil {
+		t.Fatalf("estimations failed: err=%v", err)
+	}
+	if len(estimations) != 4 || estimations[1].QError() != 40 || estimations[3].QError() != 4 {
+		t.Fatalf("bad estimations: %+v", estimations)
+	}
+
+	if failure := checkCardinality(plan, "", 50); failure != "" {
+		t.Errorf("q-error 40 is within 50: %s", failure)
+	}
+	if failure := checkCardinality(plan, "", 10); !strings.Contains(failure, "IndexLookUp_20") {
+		t.Errorf("IndexLookUp should exceed 10: %s", failure)
+	}
+	if failure := checkCardinality(plan, "TableRowIDScan", 10); failure != "" {
+		t.Errorf("TableRowIDScan is within 10: %s", failure)
+	}
+	if failure := checkCardinality(plan, "TableRowIDScan_18", 2); !strings.Contains(failure, "exceeds") {
+		t.Errorf("TableRowIDScan_18 should exceed 2: %s", failure)
+	}
+	if failure := checkCardinality(plan, "HashJoin", 2); !strings.Contains(failure, "not found") {
+		t.Errorf("HashJoin should not be found: %s", failure)
+	}
+
+	oldHeader := []string{"id", "count", "task", "operator info", "execution info"}
+	old := "TableReader_5\t10000.00\troot\tdata:TableScan_4\ttime:1ms, loops:1, rows:3\n" +
+		"└─TableScan_4\t10000.00\tcop\ttable:user, range:[-inf,+inf], keep order:false, stats:pseudo\trows:3"
+	if plan, err = newTestResult(oldHeader, old).Plan(); err != nil {
+		t.Fatalf("parse failed: err=%v", err)
+	}
+	if plan.ActRows != "3" || plan.Children[0].ActRows != "3" {
+		t.Fatalf("actRows should be read from the execution info: %+v", plan)
+	}
+
+	if explainAnalyzeSQL("select 1") != "EXPLAIN ANALYZE select 1" || explainAnalyzeSQL("explain  analyze select 1") != "explain  analyze select 1" {
+		t.Errorf("bad explain analyze sql")
+	}
+}
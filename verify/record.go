@@ -0,0 +1,312 @@
+package verify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// record modes, in which the expects are rewritten with the actual results
+// instead of compared with them.
+const (
+	RecordNone    = iota
+	RecordMissing // record the empty expects, compare the others.
+	RecordAll     // record every expect.
+)
+
+// whether the expect of the assert can be recorded from its result. The
+// expects of plan_match are patterns written by hand, an assert checked by
+// other expectations only does not need one.
+func (assert *Assert) recordable() bool {
+	switch assert.Type {
+	case ASSERT_TYPE_ADMIN, ASSERT_TYPE_PLAN_MATCH, ASSERT_TYPE_CARDINALITY, ASSERT_TYPE_CONSISTENCY:
+		return false
+	case ASSERT_TYPE_INVARIANT:
+		return assert.Expect != ""
+	}
+	if assert.ExpectError != nil {
+		return false
+	}
+	return assert.Expect != "" || (len(assert.PlanChecks) == 0 && !assert.hasAlternativeExpect())
+}
+
+// whether the assert is recorded instead of compared in the record mode.
+func (assert *Assert) recordIn(mode int) bool {
+	switch mode {
+	case RecordMissing:
+		return assert.Expect == "" && assert.recordable()
+	case RecordAll:
+		return assert.recordable()
+	}
+	return false
+}
+
+// ExpectUpdate is a new expect of an assert in a verify file.
+type ExpectUpdate struct {
+	Verify int    // index in the verify file.
+	Assert int    // index in asserts.
+	File   string // the expect file of the assert, "" if the expect is in the verify file.
+	Old    string
+	New    string
+}
+
+// Recorded are the expects recorded in the last run, also the ones equal to
+// the current expects, index is the index of the verification in the verify file.
+func (v *Verify) Recorded(index int) []ExpectUpdate {
+	var recorded []ExpectUpdate
+	for i, res := range v.Results {
+		if res.Recorded {
+			recorded = append(recorded, ExpectUpdate{Verify: index, Assert: i, File: v.Asserts[i].expectPath, Old: v.Asserts[i].Expect, New: res.Actual})
+		}
+	}
+	return recorded
+}
+
+// Changed reports whether the recorded expect differs from the current one.
+func (u ExpectUpdate) Changed() bool {
+	return u.Old != u.New
+}
+
+// Updates are the expects recorded in the last run which differ from the
+// current ones, index is the index of the verification in the verify file.
+func (v *Verify) Updates(index int) []ExpectUpdate {
+	var updates []ExpectUpdate
+	for _, u := range v.Recorded(index) {
+		if u.Changed() {
+			updates = append(updates, u)
+		}
+	}
+	return updates
+}
+
+// the JSON string literal of s, with <, > and & as they are.
+func jsonString(s string) []byte {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(s)
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
+
+// an edit of the verify file, replacing data[start:end] by text.
+type jsonEdit struct {
+	start, end int
+	text       []byte
+}
+
+// the offsets of a member of a JSON object, the value ones are of its first token.
+type jsonMember struct {
+	keyStart, keyEnd     int
+	valueStart, valueEnd int
+}
+
+// jsonScanner reads a JSON document by tokens, tracking their offsets.
+type jsonScanner struct {
+	data    []byte
+	decoder *json.Decoder
+}
+
+func (s *jsonScanner) token() (json.Token, int, int, error) {
+	// the decoder skips the spaces, commas and colons before a token.
+	prev := int(s.decoder.InputOffset())
+	t, err := s.decoder.Token()
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	end := int(s.decoder.InputOffset())
+	start := prev
+	for start < end && strings.ContainsRune(" \t\r\n,:", rune(s.data[start])) {
+		start++
+	}
+	return t, start, end, nil
+}
+
+func (s *jsonScanner) expectDelim(d json.Delim) error {
+	t, start, _, err := s.token()
+	if err != nil {
+		return err
+	}
+	if t != d {
+		return fmt.Errorf("expect %v at offset %d, got %v", d, start, t)
+	}
+	return nil
+}
+
+// skip the rest of a value whose first token is t.
+func (s *jsonScanner) skip(t json.Token) error {
+	if t != json.Delim('{') && t != json.Delim('[') {
+		return nil
+	}
+	for depth := 1; depth > 0; {
+		t, _, _, err := s.token()
+		if err != nil {
+			return err
+		}
+		switch t {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+	}
+	return nil
+}
+
+// read the members of an object after its {, visit reads the rest of each
+// value from its first token, the values are skipped if it is nil.
+func (s *jsonScanner) object(visit func(key string, first json.Token) error) (map[string]jsonMember, error) {
+	members := make(map[string]jsonMember)
+	for {
+		t, keyStart, keyEnd, err := s.token()
+		if err != nil {
+			return nil, err
+		}
+		if t == json.Delim('}') {
+			return members, nil
+		}
+		key, ok := t.(string)
+		if !ok {
+			return nil, fmt.Errorf("expect a key at offset %d", keyStart)
+		}
+		value, valueStart, valueEnd, err := s.token()
+		if err != nil {
+			return nil, err
+		}
+		if visit != nil {
+			if err := visit(key, value); err != nil {
+				return nil, err
+			}
+		} else if err := s.skip(value); err != nil {
+			return nil, err
+		}
+		members[key] = jsonMember{keyStart: keyStart, keyEnd: keyEnd, valueStart: valueStart, valueEnd: valueEnd}
+	}
+}
+
+// the edit setting the expect of an assert object.
+func (s *jsonScanner) expectEdit(members map[string]jsonMember, expect string) (jsonEdit, error) {
+	if m, ok := members["expect"]; ok {
+		return jsonEdit{start: m.valueStart, end: m.valueEnd, text: jsonString(expect)}, nil
+	}
+	// insert the expect after the sql, indented and separated like it.
+	sql, ok := members["sql"]
+	if !ok {
+		return jsonEdit{}, errors.New("an assert to update has no sql")
+	}
+	indentStart := sql.keyStart
+	for indentStart > 0 && strings.ContainsRune(" \t\r\n", rune(s.data[indentStart-1])) {
+		indentStart--
+	}
+	indent := s.data[indentStart:sql.keyStart]
+	if len(indent) == 0 {
+		indent = []byte(" ")
+	}
+	separator := s.data[sql.keyEnd:sql.valueStart]
+	var text bytes.Buffer
+	text.WriteString(",")
+	text.Write(indent)
+	text.Write(jsonString("expect"))
+	text.Write(separator)
+	text.Write(jsonString(expect))
+	return jsonEdit{start: sql.valueEnd, end: sql.valueEnd, text: text.Bytes()}, nil
+}
+
+// RewriteExpects sets the expects of the updates in the verify file data and
+// keeps everything else, like the indentation and the order of the keys, as it is.
+// An absent expect is added after the sql of the assert.
+func RewriteExpects(data []byte, updates []ExpectUpdate) ([]byte, error) {
+	byAssert := make(map[[2]int]string)
+	for _, u := range updates {
+		byAssert[[2]int{u.Verify, u.Assert}] = u.New
+	}
+
+	s := &jsonScanner{data: data, decoder: json.NewDecoder(bytes.NewReader(data))}
+	var edits []jsonEdit
+	if err := s.expectDelim('['); err != nil {
+		return nil, err
+	}
+	for verifyIndex := 0; ; verifyIndex++ {
+		t, start, _, err := s.token()
+		if err != nil {
+			return nil, err
+		}
+		if t == json.Delim(']') {
+			break
+		}
+		if t != json.Delim('{') {
+			return nil, fmt.Errorf("expect a verification at offset %d", start)
+		}
+		_, err = s.object(func(key string, first json.Token) error {
+			if key != "asserts" {
+				return s.skip(first)
+			}
+			if first != json.Delim('[') {
+				return fmt.Errorf("asserts of verification %d is not an array", verifyIndex)
+			}
+			for assertIndex := 0; ; assertIndex++ {
+				t, start, _, err := s.token()
+				if err != nil {
+					return err
+				}
+				if t == json.Delim(']') {
+					return nil
+				}
+				if t != json.Delim('{') {
+					return fmt.Errorf("expect an assert at offset %d", start)
+				}
+				members, err := s.object(nil)
+				if err != nil {
+					return err
+				}
+				if expect, ok := byAssert[[2]int{verifyIndex, assertIndex}]; ok {
+					edit, err := s.expectEdit(members, expect)
+					if err != nil {
+						return err
+					}
+					edits = append(edits, edit)
+				}
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(edits) != len(byAssert) {
+		return nil, fmt.Errorf("%d of %d asserts to update not found", len(byAssert)-len(edits), len(byAssert))
+	}
+
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+	for _, e := range edits {
+		data = append(data[:e.start:e.start], append(e.text, data[e.end:]...)...)
+	}
+	return data, nil
+}
+
+// UpdateVerificationFile rewrites the expects of the updates in the verify
+// file, or in their expect files if they have one.
+func UpdateVerificationFile(path string, updates []ExpectUpdate) error {
+	var inFile []ExpectUpdate
+	for _, u := range updates {
+		if u.File == "" {
+			inFile = append(inFile, u)
+		} else if err := WriteExpectFile(u.File, u.New); err != nil {
+			return err
+		}
+	}
+	if len(inFile) == 0 {
+		return nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	data, err = RewriteExpects(data, inFile)
+	if err != nil {
+		return fmt.Errorf("rewrite %s: %v", path, err)
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
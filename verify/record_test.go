@@ -0,0 +1,74 @@
+package verify
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestRewriteExpects(t *testing.T) {
+	data, err := ioutil.ReadFile("../test-cases/plan-sample/verification.json")
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	updates := []ExpectUpdate{
+		{Verify: 0, Assert: 0, New: "IndexScan"},
+		{Verify: 0, Assert: 1, New: "TableScan"},
+		{Verify: 0, Assert: 3, New: "Limit\troot\toffset:0, count:1\n└─TableReader\troot\tdata:Limit <&>"},
+	}
+	rewritten, err := RewriteExpects(data, updates)
+	if err != nil {
+		t.Fatalf("rewrite failed: %v", err)
+	}
+	verifies, err := LoadVerificationFromData(rewritten)
+	if err != nil {
+		t.Fatalf("bad json: %v\n%s", err, rewritten)
+	}
+	for _, u := range updates {
+		if actual := verifies[0].Asserts[u.Assert].Expect; actual != u.New {
+			t.Fatalf("expect of assert %d not updated: %q", u.Assert, actual)
+		}
+	}
+
+	// everything else is kept as it is.
+	expect := strings.Replace(string(data), `"expect": "TableScan",`, `"expect": "IndexScan",`, 1)
+	checks := `"adjust": ["ANALYZE TABLE unknown_correlation;"],
+        "plan_checks"`
+	expect = strings.Replace(expect, checks, `"expect": "TableScan",
+        `+checks, 1)
+	lines := strings.Split(expect, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, `        "expect": "Limit\troot`) {
+			lines[i] = `        "expect": "Limit\troot\toffset:0, count:1\n└─TableReader\troot\tdata:Limit <&>"`
+		}
+	}
+	expect = strings.Join(lines, "\n")
+	if string(rewritten) != expect {
+		t.Fatalf("formatting changed:\n%s", rewritten)
+	}
+
+	if _, err := RewriteExpects(data, []ExpectUpdate{{Verify: 3, Assert: 0, New: "x"}}); err == nil {
+		t.Fatalf("update of an absent assert accepted")
+	}
+}
+
+func TestAssert_Recordable(t *testing.T) {
+	cases := []struct {
+		assert  Assert
+		missing bool
+		all     bool
+	}{
+		{Assert{Type: ASSERT_TYPE_PLAN, Expect: "TableScan"}, false, true},
+		{Assert{Type: ASSERT_TYPE_PLAN}, true, true},
+		{Assert{Type: ASSERT_TYPE_PLAN, PlanChecks: []PlanCheck{{Operator: "TableScan"}}}, false, false},
+		{Assert{Type: ASSERT_TYPE_RESULT, ExpectRegex: "^1"}, false, false},
+		{Assert{Type: ASSERT_TYPE_PLAN_MATCH, Expect: "Limit > TableReader"}, false, false},
+		{Assert{Type: ASSERT_TYPE_INVARIANT}, false, false},
+		{Assert{Type: "query", ExpectError: &ExpectError{Code: 1062}}, false, false},
+	}
+	for i, c := range cases {
+		if c.assert.recordIn(RecordMissing) != c.missing || c.assert.recordIn(RecordAll) != c.all || c.assert.recordIn(RecordNone) {
+			t.Fatalf("bad record mode of assert %d: %+v", i, c.assert)
+		}
+	}
+}
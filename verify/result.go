@@ -0,0 +1,218 @@
+package verify
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NULL in the expect string of the result assert, like the mysql batch output.
+const nullString = `\N`
+
+// default relative tolerance of FLOAT and DOUBLE columns.
+const defaultFloatTolerance = 1e-9
+
+// resultCell is one value of a result set, NULL is not the empty string.
+type resultCell struct {
+	value string
+	null  bool
+}
+
+func (c resultCell) String() string {
+	if c.null {
+		return nullString
+	}
+	return c.value
+}
+
+func formatRow(row []resultCell) string {
+	cols := make([]string, 0, len(row))
+	for _, c := range row {
+		cols = append(cols, c.String())
+	}
+	return strings.Join(cols, "\t")
+}
+
+// the rows of the result, a nil column scanned from the driver is NULL.
+func (result *SqlQueryResult) cells() [][]resultCell {
+	rows := make([][]resultCell, 0, len(result.data))
+	for _, row := range result.data {
+		cells := make([]resultCell, 0, len(row))
+		for _, col := range row {
+			cells = append(cells, resultCell{value: string(col), null: col == nil})
+		}
+		rows = append(rows, cells)
+	}
+	return rows
+}
+
+// parse an expect string of the result assert, rows split by \n, columns by \t
+// and \N for NULL. The empty string is the empty result set.
+func parseExpectCells(expect string) [][]resultCell {
+	var rows [][]resultCell
+	if expect == "" {
+		return rows
+	}
+	for _, line := range strings.Split(expect, "\n") {
+		var cells []resultCell
+		for _, col := range strings.Split(line, "\t") {
+			cells = append(cells, resultCell{value: col, null: col == nullString})
+		}
+		rows = append(rows, cells)
+	}
+	return rows
+}
+
+// ToResultString is ToOneString with NULL printed as \N, the expect format of the result assert.
+func (result *SqlQueryResult) ToResultString() string {
+	lines := make([]string, 0, len(result.data))
+	for _, row := range result.cells() {
+		lines = append(lines, formatRow(row))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// type names of the columns as reported by the driver, "" if unknown.
+func (result *SqlQueryResult) columnTypeNames() []string {
+	names := make([]string, len(result.header))
+	for i := range names {
+		if i < len(result.columnTypes) && result.columnTypes[i] != nil {
+			names[i] = result.columnTypes[i].DatabaseTypeName()
+		}
+	}
+	return names
+}
+
+// resultComparator compares values by the type of their column.
+type resultComparator struct {
+	types     []string
+	tolerance float64 // relative tolerance of FLOAT and DOUBLE columns.
+}
+
+func (rc *resultComparator) equalCell(col int, expect, actual resultCell) bool {
+	if expect.null || actual.null {
+		return expect.null == actual.null
+	}
+	if expect.value == actual.value {
+		return true
+	}
+
+	typ := ""
+	if col < len(rc.types) {
+		typ = strings.ToUpper(rc.types[col])
+	}
+	switch {
+	case typ == "FLOAT" || typ == "DOUBLE":
+		e, err1 := strconv.ParseFloat(expect.value, 64)
+		a, err2 := strconv.ParseFloat(actual.value, 64)
+		return err1 == nil && err2 == nil && math.Abs(e-a) <= rc.tolerance*math.Max(math.Abs(e), math.Abs(a))
+	case strings.HasSuffix(typ, "INT") || typ == "YEAR":
+		e, ok1 := new(big.Int).SetString(expect.value, 10)
+		a, ok2 := new(big.Int).SetString(actual.value, 10)
+		return ok1 && ok2 && e.Cmp(a) == 0
+	case typ == "DATE" || typ == "DATETIME" || typ == "TIMESTAMP":
+		e, err1 := parseResultTime(expect.value)
+		a, err2 := parseResultTime(actual.value)
+		return err1 == nil && err2 == nil && e.Equal(a)
+	default:
+		// DECIMAL and strings are compared exactly.
+		return false
+	}
+}
+
+var resultTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+func parseResultTime(s string) (time.Time, error) {
+	var err error
+	for _, layout := range resultTimeLayouts {
+		var t time.Time
+		if t, err = time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+func (rc *resultComparator) equalRow(expect, actual []resultCell) bool {
+	if len(expect) != len(actual) {
+		return false
+	}
+	for i := range expect {
+		if !rc.equalCell(i, expect[i], actual[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// compare the rows in order, returns a failure message or "" if equal.
+func (rc *resultComparator) compareOrdered(expect, actual [][]resultCell) string {
+	for i := 0; i < len(expect) && i < len(actual); i++ {
+		if !rc.equalRow(expect[i], actual[i]) {
+			return fmt.Sprintf("row %d is not equals to expect:\n%s", i+1, diffString(formatRow(expect[i]), formatRow(actual[i])))
+		}
+	}
+	if len(expect) != len(actual) {
+		return fmt.Sprintf("row count %d is not equals to expect %d", len(actual), len(expect))
+	}
+	return ""
+}
+
+// compare the rows as multisets, returns a failure message or "" if equal.
+func (rc *resultComparator) compareUnordered(expect, actual [][]resultCell) string {
+	matched := make([]bool, len(actual))
+	var missing []string
+	for _, e := range expect {
+		found := false
+		for j, a := range actual {
+			if !matched[j] && rc.equalRow(e, a) {
+				matched[j], found = true, true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, formatRow(e))
+		}
+	}
+	var unexpected []string
+	for j, a := range actual {
+		if !matched[j] {
+			unexpected = append(unexpected, formatRow(a))
+		}
+	}
+	if len(missing) == 0 && len(unexpected) == 0 {
+		return ""
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("result set is not equals to expect, ignoring row order")
+	if len(missing) > 0 {
+		buf.WriteString("\nmissing rows:\n" + strings.Join(missing, "\n"))
+	}
+	if len(unexpected) > 0 {
+		buf.WriteString("\nunexpected rows:\n" + strings.Join(unexpected, "\n"))
+	}
+	return buf.String()
+}
+
+// compare the result with an expect string of the result assert, returns a
+// failure message or "" if equal.
+func (result *SqlQueryResult) compareRows(expect string, ordered bool, tolerance float64) string {
+	if tolerance <= 0 {
+		tolerance = defaultFloatTolerance
+	}
+	rc := &resultComparator{types: result.columnTypeNames(), tolerance: tolerance}
+	if ordered {
+		return rc.compareOrdered(parseExpectCells(expect), result.cells())
+	}
+	return rc.compareUnordered(parseExpectCells(expect), result.cells())
+}
@@ -0,0 +1,45 @@
+package verify
+
+import (
+	"testing"
+)
+
+func TestResultComparator(t *testing.T) {
+	rc := &resultComparator{types: []string{"INT", "DOUBLE", "DECIMAL", "DATETIME", "VARCHAR"}, tolerance: 1e-6}
+	actual := [][]resultCell{
+		{{value: "1"}, {value: "0.30000000000000004"}, {value: "1.10"}, {value: "2019-05-16 00:00:00"}, {value: ""}},
+		{{value: "2"}, {value: "2.5"}, {value: "2.00"}, {value: "2019-05-17 10:00:00.000"}, {null: true}},
+	}
+
+	expects := []struct {
+		expect    string
+		ordered   bool
+		equals    bool
+		rowsCount int
+	}{
+		{"1\t0.3\t1.10\t2019-05-16 00:00:00\t\n2\t2.5\t2.00\t2019-05-17 10:00:00\t\\N", true, true, 2},
+		{"2\t2.5\t2.00\t2019-05-17 10:00:00\t\\N\n1\t0.3\t1.10\t2019-05-16\t", false, true, 2},
+		{"2\t2.5\t2.00\t2019-05-17 10:00:00\t\\N\n1\t0.3\t1.10\t2019-05-16\t", true, false, 2},
+		// DECIMAL is compared exactly.
+		{"1\t0.3\t1.1\t2019-05-16\t\n2\t2.5\t2.00\t2019-05-17 10:00:00\t\\N", false, false, 2},
+		// NULL is not the empty string.
+		{"1\t0.3\t1.10\t2019-05-16\t\\N\n2\t2.5\t2.00\t2019-05-17 10:00:00\t", false, false, 2},
+		{"1\t0.3\t1.10\t2019-05-16\t", false, false, 1},
+		{"", false, false, 0},
+	}
+	for i, e := range expects {
+		rows := parseExpectCells(e.expect)
+		if len(rows) != e.rowsCount {
+			t.Fatalf("expect %d should have %d rows, got %d", i, e.rowsCount, len(rows))
+		}
+		var failure string
+		if e.ordered {
+			failure = rc.compareOrdered(rows, actual)
+		} else {
+			failure = rc.compareUnordered(rows, actual)
+		}
+		if (failure == "") != e.equals {
+			t.Errorf("expect %d should be equals: %v, %s", i, e.equals, failure)
+		}
+	}
+}
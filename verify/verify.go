@@ -2,9 +2,9 @@ package verify
 
 import (
 	"bytes"
+	"concurrent-sql/util"
 	"database/sql"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -19,6 +19,14 @@ const (
 	RUN_ONETIME       = "dml_end"
 	ASSERT_TYPE_ADMIN = "admin_check"
 	ASSERT_TYPE_PLAN  = "plan"
+
+	ASSERT_TYPE_PLAN_MATCH = "plan_match"
+	ASSERT_TYPE_EXPLAIN    = "explain"
+
+	ASSERT_TYPE_CARDINALITY = "cardinality"
+	ASSERT_TYPE_RESULT      = "result"
+	ASSERT_TYPE_INVARIANT   = "invariant"
+	ASSERT_TYPE_CONSISTENCY = "consistency"
 )
 
 type SQLAssert interface {
@@ -26,17 +34,68 @@ type SQLAssert interface {
 }
 
 type Verify struct {
-	RunAt   string   `json:"run_at"`
-	Sleep   int      `json:"wait,omitempty"`
-	Asserts []Assert `json:"asserts,omitempty"`
-	DSN     string   `json:"-"`
+	RunAt   string      `json:"run_at"`
+	Sleep   int         `json:"wait,omitempty"`
+	Asserts []Assert    `json:"asserts,omitempty"`
+	DSN     string      `json:"-"`
+	Log     *log.Logger `json:"-"`
+
+	// the results of the asserts by index, set when they run.
+	Results []AssertResult `json:"-"`
+	// one of the record modes, RecordNone to compare the results with the expects.
+	Record int `json:"-"`
+
+	// the database named in the case files and the one the case runs in, which
+	// is printed back as the former in query results.
+	database, runDatabase string
 }
 
-func (v *Verify) RunAsync(c chan string, shutdown chan struct{}) {
+// UseDatabase rewrites the sqls naming database from to name database to, and
+// query results to name from again, so the expects do not change.
+func (v *Verify) UseDatabase(from, to string) error {
+	if v.database == "" {
+		v.database = from
+	}
+	v.runDatabase = to
+	for i := range v.Asserts {
+		as := &v.Asserts[i]
+		var err error
+		if as.SQL, err = util.RenameDatabase(as.SQL, from, to); err != nil {
+			return err
+		}
+		for _, queries := range [][]string{as.Adjust, as.Clean, as.SQLs} {
+			if err := util.RenameDatabaseInAll(queries, from, to); err != nil {
+				return err
+			}
+		}
+		if strings.HasPrefix(strings.ToLower(as.IndexVariants), strings.ToLower(from)+".") {
+			as.IndexVariants = to + as.IndexVariants[len(from):]
+		}
+	}
+	return nil
+}
+
+func (v *Verify) logger() *log.Logger {
+	return util.Logger(v.Log)
+}
+
+// run the query, with the database of the run renamed back in the result.
+func (v *Verify) query(db *sql.DB, query string) (*SqlQueryResult, error) {
+	v.logger().Println("executing sql:", query)
+	result, err := GetQueryResult(db, query)
+	if err == nil && v.runDatabase != "" && v.runDatabase != v.database {
+		result.renameDatabase(v.runDatabase, v.database)
+	}
+	return result, err
+}
+
+// RunAsync runs the asserts until the first failure, which is sent to c, or
+// until shutdown if the verification repeats.
+func (v *Verify) RunAsync(c chan error, shutdown chan struct{}) {
 	defer close(c)
 	db, err := sql.Open("mysql", v.DSN)
 	if err != nil {
-		c <- fmt.Sprintf("%v", err)
+		c <- err
 		return
 	}
 	defer func() {
@@ -47,41 +106,119 @@ func (v *Verify) RunAsync(c chan string, shutdown chan struct{}) {
 		select {
 		case msg1 := <-shutdown:
 			{
-				log.Println("shutdown signal received", msg1)
+				v.logger().Println("shutdown signal received", msg1)
 				return
 			}
 		default:
-			log.Println("no shutdown signal")
+			v.logger().Println("no shutdown signal")
 		}
 
-		log.Println("start to execute verify case")
+		v.logger().Println("start to execute verify case")
 		err := v.Assert(db)
 		if err != nil {
-			c <- fmt.Sprintf("%v", err)
+			c <- err
 			return
 		}
 		if v.RunAt == RUN_ONETIME {
 			return
 		}
-		log.Printf("execute done, sleep, %d", v.Sleep)
+		v.logger().Printf("execute done, sleep, %d", v.Sleep)
 		time.Sleep(time.Duration(v.Sleep) * time.Second)
 	}
 }
 
+// Prepare captures the baseline of the invariant asserts without an expect,
+// it runs before the dml starts.
+func (v *Verify) Prepare() error {
+	var db *sql.DB
+	for i := range v.Asserts {
+		as := &v.Asserts[i]
+		if as.Type != ASSERT_TYPE_INVARIANT || as.Expect != "" {
+			continue
+		}
+		if db == nil {
+			var err error
+			if db, err = sql.Open("mysql", v.DSN); err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+		}
+		result, err := v.query(db, as.SQL)
+		if err != nil {
+			return err
+		}
+		baseline := result.ToOneString()
+		as.baseline = &baseline
+		v.logger().Printf("invariant baseline captured: %s", baseline)
+	}
+	return nil
+}
+
 type Assert struct {
 	Type   string   `json:"type,omitempty"`
 	SQL    string   `json:"sql,omitempty"`
 	Adjust []string `json:"adjust,omitempty"`
 	Expect string   `json:"expect,omitempty"`
 	Clean  []string `json:"clean,omitempty"`
+
+	// file holding the raw expect, relative to the case directory, instead of expect.
+	ExpectFile string `json:"expect_file,omitempty"`
+
+	// structured expectations of the plan assert, checked on the parsed EXPLAIN tree.
+	PlanChecks []PlanCheck `json:"plan_checks,omitempty"`
+
+	// the explain assert compares plans with operator ids stripped, these columns
+	// are dropped and estRows may differ by this relative tolerance.
+	IgnoreColumns    []string `json:"ignore_columns,omitempty"`
+	EstRowsTolerance float64  `json:"est_rows_tolerance,omitempty"`
+
+	// the cardinality assert runs the sql under EXPLAIN ANALYZE and fails when the
+	// q-error of the operator, or of any operator if it is empty, exceeds MaxQError.
+	MaxQError float64 `json:"max_q_error,omitempty"`
+	Operator  string  `json:"operator,omitempty"`
+
+	// the result assert compares typed values, ignoring the row order unless the
+	// sql has an ORDER BY, Ordered overrides it. Tolerance is the relative
+	// tolerance of FLOAT and DOUBLE columns.
+	Ordered   *bool   `json:"ordered,omitempty"`
+	Tolerance float64 `json:"tolerance,omitempty"`
+
+	// expectations besides the exact expect, checked on the same result string.
+	ExpectRegex    string   `json:"expect_regex,omitempty"`
+	ExpectContains string   `json:"expect_contains,omitempty"`
+	ExpectGlob     string   `json:"expect_glob,omitempty"`
+	ExpectAnyOf    []string `json:"expect_any_of,omitempty"`
+
+	// the sql must fail with this error, the result is not checked.
+	ExpectError *ExpectError `json:"expect_error,omitempty"`
+
+	// the consistency assert requires the result sets of sql, sqls and the index
+	// variants of sql on the table to be equal, compared like the result assert.
+	SQLs          []string `json:"sqls,omitempty"`
+	IndexVariants string   `json:"index_variants,omitempty"`
+
+	// result of the invariant assert captured before the dml starts, used when expect is empty.
+	baseline *string
+	// the path of ExpectFile.
+	expectPath string
+}
+
+// the sql actually executed for the assert.
+func (assert *Assert) query() string {
+	if assert.Type == ASSERT_TYPE_CARDINALITY {
+		return explainAnalyzeSQL(assert.SQL)
+	}
+	return assert.SQL
 }
 
 //clean assert variable data
-func (assert *Assert) CleanEnv(db *sql.DB) {
+func (assert *Assert) CleanEnv(db *sql.DB, logger *log.Logger) {
 	for _, query := range assert.Clean {
 		_, err := GetQueryResult(db, query)
 		if err != nil {
-			log.Println("execute clean sql failed! ", query)
+			util.Logger(logger).Println("execute clean sql failed! ", query)
 		}
 	}
 }
@@ -101,61 +238,206 @@ func LoadVerificationFromFile(filePath string) ([]Verify, error) {
 	return LoadVerificationFromData(jsonData)
 }
 
+// AssertError is the failure of one assert of a verification, either the sql
+// failed with Err, or the result did not meet the expectation described by Failure.
+type AssertError struct {
+	Index   int // of the assert in asserts.
+	SQL     string
+	Failure string
+	Err     error
+}
+
+func (e *AssertError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("assert %d: %v", e.Index, e.Err)
+	}
+	return fmt.Sprintf("assert %d: verify case failed", e.Index)
+}
+
 func (verify *Verify) Assert(db *sql.DB) error {
-	for _, as := range verify.Asserts {
-		queryResult, err := GetQueryResult(db, as.SQL)
+	if len(verify.Results) != len(verify.Asserts) {
+		verify.Results = make([]AssertResult, len(verify.Asserts))
+	}
+	for i := range verify.Asserts {
+		as := &verify.Asserts[i]
+		res := &verify.Results[i]
+		start := time.Now()
+		*res = AssertResult{Runs: res.Runs + 1}
+		failure, err := verify.assert(db, as, res)
+		res.Duration, res.Failure, res.Err = time.Since(start), failure, err
+		if err != nil || failure != "" {
+			return &AssertError{Index: i, SQL: as.SQL, Failure: failure, Err: err}
+		}
+	}
+	return nil
+}
+
+// run one assert, returns the failure message if the result is not the expected one.
+func (verify *Verify) assert(db *sql.DB, as *Assert, res *AssertResult) (string, error) {
+	if as.Type == ASSERT_TYPE_CONSISTENCY {
+		failure, err := as.checkConsistency(db, verify.logger())
+		as.CleanEnv(db, verify.logger())
 		if err != nil {
-			return err
+			return "", err
 		}
-		switch as.Type {
-		case ASSERT_TYPE_ADMIN:
-			log.Println("admin check without error")
-		default:
-			stringFunc := queryResult.getQueryResultStringFunc(as.Type)
-			queryResultStr := stringFunc()
-			equals := true
-			if queryResultStr != as.Expect {
-				fmt.Println("Result is not equals to Expect")
-				printDiff(as.Expect, queryResultStr)
-				equals = false
-				//now adjust
-				for _, adjust := range as.Adjust {
-					log.Printf("try to adjust sql: %s\n", adjust)
-					_, err := db.Exec(adjust)
-					if err != nil {
-						log.Printf("execute adjust failed\n")
-						return err
-					}
-					// check again
-
-					queryResult, err := GetQueryResult(db, as.SQL)
-					if err != nil {
-						return err
-					}
-					stringFunc := queryResult.getQueryResultStringFunc(as.Type)
-					queryResultStr = stringFunc()
-					if queryResultStr == as.Expect {
-						equals = true
-						break
-					} else {
-						log.Println("Result is not equals to Expect")
-						printDiff(as.Expect, queryResultStr)
-					}
-				}
-			}
+		if failure != "" {
+			verify.logger().Println(failure)
+			return failure, nil
+		}
+		verify.logger().Println("consistency assert successfully!")
+		return "", nil
+	}
 
-			//let's clean env first
-			as.CleanEnv(db)
-			if !equals {
-				fmt.Println("the sql result not equals")
-				return errors.New("verify case failed")
-			} else {
-				log.Println("plan assert successfully!")
+	queryResult, err := verify.query(db, as.query())
+	if err == nil {
+		res.record(as, queryResult)
+	}
+	if as.ExpectError != nil {
+		if err != nil {
+			res.Actual = err.Error()
+		}
+		as.CleanEnv(db, verify.logger())
+		if failure := as.ExpectError.check(err); failure != "" {
+			verify.logger().Println(failure)
+			return failure, nil
+		}
+		verify.logger().Println("expected error received: ", err)
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if as.recordIn(verify.Record) {
+		// record the result after the adjust steps, which the next runs compare.
+		if failure := as.compare(queryResult); failure != "" {
+			if _, err := verify.adjust(db, as, res, failure); err != nil {
+				return "", err
 			}
 		}
+		res.Recorded = true
+		as.CleanEnv(db, verify.logger())
+		verify.logger().Println("expect recorded: ", res.Actual)
+		return "", nil
+	}
+	switch as.Type {
+	case ASSERT_TYPE_ADMIN:
+		verify.logger().Println("admin check without error")
+	default:
+		failure := as.compare(queryResult)
+		if failure != "" {
+			verify.logger().Println(failure)
+			//now adjust
+			if failure, err = verify.adjust(db, as, res, failure); err != nil {
+				return "", err
+			}
+		}
+		equals := failure == ""
 
+		//let's clean env first
+		as.CleanEnv(db, verify.logger())
+		if !equals {
+			verify.logger().Println("the sql result not equals")
+			return failure, nil
+		} else {
+			verify.logger().Println("plan assert successfully!")
+		}
 	}
-	return nil
+	return "", nil
+}
+
+// run the adjust steps of the assert one by one until its result is the
+// expected one, returns the mismatch of the last result, "" if one matched.
+// failure is the mismatch of the result before the adjust steps.
+func (verify *Verify) adjust(db *sql.DB, as *Assert, res *AssertResult, failure string) (string, error) {
+	for _, adjust := range as.Adjust {
+		verify.logger().Printf("try to adjust sql: %s\n", adjust)
+		_, err := db.Exec(adjust)
+		if err != nil {
+			verify.logger().Printf("execute adjust failed\n")
+			return "", err
+		}
+		// check again
+
+		queryResult, err := verify.query(db, as.query())
+		if err != nil {
+			return "", err
+		}
+		res.record(as, queryResult)
+		if failure = as.compare(queryResult); failure == "" {
+			return "", nil
+		}
+		verify.logger().Println(failure)
+	}
+	return failure, nil
+}
+
+// compare the query result with the expectation of the assert, returns a
+// message describing the mismatch, or "" if the result is the expected one.
+func (assert *Assert) compare(result *SqlQueryResult) string {
+	if assert.Type == ASSERT_TYPE_CARDINALITY {
+		plan, err := result.Plan()
+		if err != nil {
+			return fmt.Sprintf("parse plan failed: %v\n%s", err, result.ToOneString())
+		}
+		if assert.MaxQError <= 0 {
+			return "max_q_error of the cardinality assert must be positive"
+		}
+		return checkCardinality(plan, assert.Operator, assert.MaxQError)
+	}
+	if assert.Type == ASSERT_TYPE_INVARIANT && assert.baseline != nil {
+		if failure := assert.compareExpect(result, *assert.baseline); failure != "" {
+			return "invariant changed since the dml started\n" + failure
+		}
+		return ""
+	}
+	if assert.Type == ASSERT_TYPE_PLAN && len(assert.PlanChecks) > 0 {
+		plan, err := result.Plan()
+		if err != nil {
+			return fmt.Sprintf("parse plan failed: %v\n%s", err, result.ToOneString())
+		}
+		if failure := checkPlan(plan, assert.PlanChecks); failure != "" {
+			return failure
+		}
+	}
+
+	// the exact expect is optional when other expectations are given.
+	if assert.Expect != "" || (len(assert.PlanChecks) == 0 && !assert.hasAlternativeExpect()) {
+		if failure := assert.compareExpect(result, assert.Expect); failure != "" {
+			return failure
+		}
+	}
+	return assert.compareAlternativeExpect(result)
+}
+
+// compare the query result with one expect string, the way of the assert type.
+func (assert *Assert) compareExpect(result *SqlQueryResult, expect string) string {
+	switch assert.Type {
+	case ASSERT_TYPE_RESULT:
+		ordered := util.HasOrderBy(assert.SQL)
+		if assert.Ordered != nil {
+			ordered = *assert.Ordered
+		}
+		return result.compareRows(expect, ordered, assert.Tolerance)
+	case ASSERT_TYPE_PLAN_MATCH:
+		plan, err := result.Plan()
+		if err != nil {
+			return fmt.Sprintf("parse plan failed: %v\n%s", err, result.ToOneString())
+		}
+		return matchPlan(plan, expect)
+	}
+
+	stringFunc := result.getQueryResultStringFunc(assert)
+	actual := stringFunc()
+	equals := actual == expect
+	if assert.Type == ASSERT_TYPE_EXPLAIN {
+		normalizer := result.newPlanNormalizer(assert.IgnoreColumns, assert.EstRowsTolerance)
+		equals = normalizer.equal(expect, result.ToOneString())
+		expect = normalizer.normalizeString(expect)
+	}
+	if !equals {
+		return "Result is not equals to Expect\n" + diffString(expect, actual)
+	}
+	return ""
 }
 
 type SqlQueryResult struct {
@@ -196,11 +478,15 @@ func getAllRecordAsString(db *sql.DB, query string) (string, error) {
 
 //get the query result
 func GetQueryResult(db *sql.DB, query string) (*SqlQueryResult, error) {
-	log.Println("executing sql:", query)
 	result, err := db.Query(query)
 	if err != nil {
 		return nil, err
 	}
+	return ReadQueryResult(result)
+}
+
+// ReadQueryResult reads all rows of the result and closes it.
+func ReadQueryResult(result *sql.Rows) (*SqlQueryResult, error) {
 	defer result.Close()
 	cols, err := result.Columns()
 	if err != nil {
@@ -210,6 +496,7 @@ func GetQueryResult(db *sql.DB, query string) (*SqlQueryResult, error) {
 	if err != nil {
 		return nil, err
 	}
+	// a NULL column is scanned as a nil slice, the empty string as an empty one.
 	var allRows [][][]byte
 	for result.Next() {
 		var columns = make([][]byte, len(cols))
@@ -227,6 +514,17 @@ func GetQueryResult(db *sql.DB, query string) (*SqlQueryResult, error) {
 	return &queryResult, nil
 }
 
+// replace the database name from by to in every value of the result.
+func (result *SqlQueryResult) renameDatabase(from, to string) {
+	for _, row := range result.data {
+		for i, col := range row {
+			if bytes.Contains(col, []byte(from)) {
+				row[i] = bytes.Replace(col, []byte(from), []byte(to), -1)
+			}
+		}
+	}
+}
+
 // readable query result like mysql shell client
 func (result *SqlQueryResult) String() string {
 	if result.data == nil || result.header == nil {
@@ -289,24 +587,36 @@ func (result *SqlQueryResult) String() string {
 	return strings.Join(lines, "\n")
 }
 
-func printDiff(expect, actual string) {
+// colored diff of the expected and actual result.
+func diffString(expect, actual string) string {
 	green := color.New(color.FgGreen).SprintFunc()
 	red := color.New(color.FgRed).SprintFunc()
+	expected, actualResult := DiffSides(expect, actual,
+		func(s string) string { return s },
+		func(s string) string { return red(s) },
+		func(s string) string { return green(s) })
+	return fmt.Sprintf("Expected Result:\n%s\nActual Result:\n%s", expected, actualResult)
+}
+
+// DiffSides diffs actual against expect and returns both, with the text only in
+// expect marked by del, the text only in actual marked by ins, and the text in
+// both by equal.
+func DiffSides(expect, actual string, equal, del, ins func(string) string) (string, string) {
 	patch := diffmatchpatch.New()
 	diff := patch.DiffMain(expect, actual, false)
 	var newExpectedContent, newActualResult bytes.Buffer
 	for _, d := range diff {
 		switch d.Type {
 		case diffmatchpatch.DiffEqual:
-			newExpectedContent.WriteString(d.Text)
-			newActualResult.WriteString(d.Text)
+			newExpectedContent.WriteString(equal(d.Text))
+			newActualResult.WriteString(equal(d.Text))
 		case diffmatchpatch.DiffDelete:
-			newExpectedContent.WriteString(red(d.Text))
+			newExpectedContent.WriteString(del(d.Text))
 		case diffmatchpatch.DiffInsert:
-			newActualResult.WriteString(green(d.Text))
+			newActualResult.WriteString(ins(d.Text))
 		}
 	}
-	fmt.Printf("Expected Result:\n%s\nActual Result:\n%s\n", newExpectedContent.String(), newActualResult.String())
+	return newExpectedContent.String(), newActualResult.String()
 }
 
 func (result *SqlQueryResult) getPlanScanType() string {
@@ -324,10 +634,16 @@ func (result *SqlQueryResult) getPlanScanType() string {
 	return ""
 }
 
-func (result *SqlQueryResult) getQueryResultStringFunc(compareType string) func() string {
-	switch compareType {
+func (result *SqlQueryResult) getQueryResultStringFunc(assert *Assert) func() string {
+	switch assert.Type {
 	case ASSERT_TYPE_PLAN:
 		return result.getPlanScanType
+	case ASSERT_TYPE_RESULT:
+		return result.ToResultString
+	case ASSERT_TYPE_EXPLAIN:
+		return func() string {
+			return result.ToNormalizedString(assert.IgnoreColumns...)
+		}
 	default:
 		return result.ToOneString
 	}
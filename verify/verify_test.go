@@ -2,10 +2,11 @@ package verify
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"testing"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 )
 
 func TestLoadVerificationFromData(t *testing.T) {
@@ -91,3 +92,58 @@ func TestVerify_Assert(t *testing.T) {
 	}
 
 }
+
+func TestAssert_CompareAlternativeExpect(t *testing.T) {
+	result := newTestResult(oldExplainHeader, samplePlan)
+	other := "TopN_8\t1.00\troot\ttest2.unknown_correlation.id:asc, offset:0, count:1\n" +
+		"└─IndexLookUp_16\t1.00\troot\t\n" +
+		"  ├─IndexScan_13\t6.00\tcop\ttable:unknown_correlation, index:a, range:[2,2], keep order:false\n" +
+		"  └─TopN_15\t1.00\tcop\ttest2.unknown_correlation.id:asc, offset:0, count:1\n" +
+		"    └─TableScan_14\t6.00\tcop\ttable:unknown_correlation, keep order:false"
+
+	asserts := []struct {
+		assert Assert
+		equals bool
+	}{
+		{Assert{Type: "query", ExpectRegex: `TableScan_\d+\t4\.17`}, true},
+		{Assert{Type: "query", ExpectRegex: `^TopN`}, false},
+		{Assert{Type: "query", ExpectRegex: `(`}, false},
+		{Assert{Type: "query", ExpectContains: "keep order:true"}, true},
+		{Assert{Type: "query", ExpectContains: "keep order:true", Expect: "xxx"}, false},
+		{Assert{Type: "query", ExpectGlob: "Limit_*TableScan_??\t*keep order:true"}, true},
+		{Assert{Type: "query", ExpectGlob: "Limit_*"}, true},
+		{Assert{Type: "query", ExpectGlob: "TableReader*"}, false},
+		{Assert{Type: "query", ExpectAnyOf: []string{other, samplePlan}}, true},
+		{Assert{Type: "query", ExpectAnyOf: []string{other}}, false},
+		{Assert{Type: ASSERT_TYPE_PLAN_MATCH, ExpectAnyOf: []string{"TopN > IndexLookUp", "Limit > TableReader"}}, true},
+		{Assert{Type: ASSERT_TYPE_PLAN, ExpectAnyOf: []string{"IndexScan", "TableScan"}}, true},
+		{Assert{Type: ASSERT_TYPE_PLAN, ExpectAnyOf: []string{"IndexScan"}, ExpectContains: "Limit"}, false},
+	}
+	for i, a := range asserts {
+		if failure := a.assert.compare(result); (failure == "") != a.equals {
+			t.Errorf("assert %d should be equals: %v, %s", i, a.equals, failure)
+		}
+	}
+}
+
+func TestExpectError_Check(t *testing.T) {
+	dupKey := &mysql.MySQLError{Number: 1062, Message: "Duplicate entry '1' for key 'PRIMARY'"}
+	checks := []struct {
+		expect ExpectError
+		err    error
+		pass   bool
+	}{
+		{ExpectError{Code: 1062}, dupKey, true},
+		{ExpectError{Code: 1213}, dupKey, false},
+		{ExpectError{Code: 1062, Message: "Duplicate entry .* for key"}, dupKey, true},
+		{ExpectError{Message: "^Error 1062"}, dupKey, true},
+		{ExpectError{Message: "deadlock"}, dupKey, false},
+		{ExpectError{Code: 1062}, errors.New("invalid connection"), false},
+		{ExpectError{}, nil, false},
+	}
+	for i, c := range checks {
+		if failure := c.expect.check(c.err); (failure == "") != c.pass {
+			t.Errorf("check %d should pass: %v, %s", i, c.pass, failure)
+		}
+	}
+}